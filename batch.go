@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dihedron/jted/generator"
+	"github.com/dihedron/jted/jenkins"
+	"github.com/dihedron/jted/sax"
+)
+
+// Batch processes all the jobs in a JENKINS_HOME (or jobs) directory tree, or
+// on a Jenkins server, and writes the generated files into an output
+// directory: either one .tpl/.hcl pair per job, in the same layout as the tree
+// (jobs/<folder>/jobs/<name>/config.xml, for the jobs on a server), or a
+// single main.tf with one jenkins_job resource per job, with the templates
+// next to it. Jobs with the same structure can be grouped, so that they share
+// a template. Failures are collected rather than aborting the whole run.
+type Batch struct {
+	Root     string                    // the JENKINS_HOME or jobs directory
+	Client   *jenkins.Client           // the client of the Jenkins server, if the jobs are fetched from it
+	Names    []string                  // the full names of the jobs to fetch from the server (default: all)
+	Output   string                    // the output directory
+	Combined bool                      // if all the resources go into a single main.tf
+	Group    bool                      // if jobs with the same structure share a template
+	Verify   bool                      // if the templates are checked against the original files
+	Handler  func() *generator.Handler // creates a handler configured for each job (or group)
+	Jobs     int                       // the number of jobs found
+	Groups   int                       // the number of groups of jobs sharing a template
+	Failures []string                  // the jobs that could not be processed, with the reason
+	hcl      bytes.Buffer              // the combined HCL
+	tf       bytes.Buffer              // the combined variables declarations
+	tfvars   bytes.Buffer              // the combined variables values
+	imports  bytes.Buffer              // the combined terraform import commands
+	labels   map[string]string         // the jobs by resource label, in the combined main.tf
+}
+
+// job is a job found in the tree, whose configuration has been parsed.
+type job struct {
+	path     string          // the path (or URL) of the config.xml
+	name     string          // the full name of the job
+	relative string          // the path of the config.xml in the tree
+	input    []byte          // the original config.xml
+	document *generator.Node // the XML tree of the config.xml
+}
+
+// Run walks the tree (or the server) and processes every job in it; it only
+// returns an error if the tree cannot be walked, the jobs on the server cannot
+// be listed or the combined files cannot be written.
+func (b *Batch) Run() error {
+	if err := os.MkdirAll(b.Output, 0755); err != nil {
+		return err
+	}
+	b.labels = map[string]string{}
+	var jobs []*job
+	var err error
+	if b.Client != nil {
+		jobs, err = b.fetch()
+	} else {
+		jobs, err = b.walk()
+	}
+	if err != nil {
+		return err
+	}
+	var groups [][]*job
+	if b.Group {
+		groups = group(jobs)
+	} else {
+		for _, j := range jobs {
+			groups = append(groups, []*job{j})
+		}
+	}
+	for _, jobs := range groups {
+		name := ""
+		if len(jobs) > 1 {
+			b.Groups++
+			name = fmt.Sprintf("group_%d", b.Groups)
+		}
+		if err := b.generate(jobs, name); err != nil {
+			for _, j := range jobs {
+				b.Failures = append(b.Failures, fmt.Sprintf("%s: %v", j.path, err))
+			}
+		}
+	}
+	if !b.Combined || b.hcl.Len() == 0 {
+		return nil
+	}
+	files := map[string]*bytes.Buffer{
+		"main.tf": &b.hcl,
+	}
+	if b.tf.Len() > 0 {
+		files["variables.tf"] = &b.tf
+		files["terraform.tfvars"] = &b.tfvars
+	}
+	for name, data := range files {
+		if err := writeFile(filepath.Join(b.Output, name), data.Bytes()); err != nil {
+			return err
+		}
+	}
+	if b.imports.Len() > 0 {
+		script := append([]byte(generator.ImportScriptHeader), b.imports.Bytes()...)
+		if err := writeScript(filepath.Join(b.Output, "imports.sh"), script); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk loads the jobs in the tree.
+func (b *Batch) walk() ([]*job, error) {
+	paths, err := findJobs(b.Root)
+	if err != nil {
+		return nil, err
+	}
+	b.Jobs = len(paths)
+	var jobs []*job
+	for _, path := range paths {
+		j := &job{path: path, name: generator.JobName(path), relative: b.relative(path)}
+		file, err := os.Open(path)
+		if err == nil {
+			err = b.load(j, file)
+			file.Close()
+		}
+		if err != nil {
+			b.Failures = append(b.Failures, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// fetch loads the jobs on the server: the given ones, or all of them.
+func (b *Batch) fetch() ([]*job, error) {
+	names := b.Names
+	if len(names) == 0 {
+		all, err := b.Client.Jobs("")
+		if err != nil {
+			return nil, fmt.Errorf("error listing jobs: %v", err)
+		}
+		for _, j := range all {
+			names = append(names, j.Name)
+		}
+	}
+	b.Jobs = len(names)
+	var jobs []*job
+	for _, name := range names {
+		relative := "config.xml"
+		segments := strings.Split(strings.Trim(name, "/"), "/")
+		for i := len(segments) - 1; i >= 0; i-- {
+			relative = filepath.Join("jobs", segments[i], relative)
+		}
+		j := &job{
+			path:     b.Client.URL + jenkins.Path(name) + "/config.xml",
+			name:     name,
+			relative: relative,
+		}
+		body, err := b.Client.ConfigXML(name)
+		if err == nil {
+			err = b.load(j, body)
+			body.Close()
+		}
+		if err != nil {
+			b.Failures = append(b.Failures, fmt.Sprintf("%s: %v", j.path, err))
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// load parses the configuration of the given job, read from the given reader,
+// which is also kept for verification.
+func (b *Batch) load(j *job, reader io.Reader) error {
+	if b.Combined {
+		label := generator.Label(j.name)
+		if other, ok := b.labels[label]; ok {
+			return fmt.Errorf("resource label %s already used by job %s", label, other)
+		}
+		b.labels[label] = j.name
+	}
+	h := b.Handler()
+	h.Deferred = true
+	parser := &sax.Parser{
+		EventHandler:   h,
+		ErrorHandler:   h,
+		LexicalHandler: h,
+	}
+	var input bytes.Buffer
+	if err := parser.Parse(io.TeeReader(reader, &input)); err != nil {
+		return fmt.Errorf("error parsing input file: %v", err)
+	}
+	j.input = input.Bytes()
+	j.document = h.Documents[0]
+	return nil
+}
+
+// generate generates the template and the HCL of the given jobs, which share
+// the template if they are more than one: the files of a group go into a
+// directory with the given name (or are named after it, in the combined
+// output), while the files of a single job go where the job is in the tree
+// (or are named after its resource).
+func (b *Batch) generate(jobs []*job, name string) error {
+	h := b.Handler()
+	documents := make([]*generator.Node, len(jobs))
+	for i, j := range jobs {
+		h.Names = append(h.Names, j.name)
+		documents[i] = j.document
+	}
+	var output string
+	switch {
+	case b.Combined && len(jobs) == 1:
+		name = generator.Label(jobs[0].name)
+		h.Prefix = name + "_"
+		output = filepath.Join(b.Output, name+".xml")
+	case b.Combined:
+		output = filepath.Join(b.Output, name+".xml")
+	case len(jobs) == 1:
+		name = jobs[0].name
+		output = filepath.Join(b.Output, jobs[0].relative)
+	default:
+		output = filepath.Join(b.Output, name, "config.xml")
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	// the HCL refers to the files next to it, wherever Terraform runs from
+	template := getConfigXMLTemplateFileName(output)
+	h.TemplateFile = "./" + filepath.Base(template)
+
+	if err := h.Generate(documents...); err != nil {
+		return fmt.Errorf("error generating template: %v", err)
+	}
+	if len(jobs) > 1 {
+		names := make([]string, len(jobs))
+		for i, j := range jobs {
+			names[i] = j.name
+		}
+		fmt.Fprintf(os.Stderr, "Template %s shared by %s\n", name, strings.Join(names, ", "))
+	}
+	for _, warning := range h.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", name, warning)
+	}
+	for _, withheld := range h.Withheld {
+		fmt.Fprintf(os.Stderr, "Secret withheld: %s: %s\n", name, withheld)
+	}
+	differences := 0
+	if b.Verify {
+		for i, j := range jobs {
+			d, err := h.Verify(i, j.input)
+			if err != nil {
+				return fmt.Errorf("error verifying template: %v", err)
+			}
+			for _, difference := range d {
+				fmt.Fprintf(os.Stderr, "Difference in %s: %s\n", j.path, difference)
+			}
+			differences += len(d)
+		}
+	}
+
+	files := map[string][]byte{}
+	if !h.EmbedConfigXML {
+		files[template] = h.ConfigXML.Bytes()
+	}
+	for file, script := range h.Scripts {
+		files[filepath.Join(filepath.Dir(output), file)] = []byte(script)
+	}
+	if b.Combined {
+		b.hcl.Write(h.HCL.Bytes())
+		b.tf.Write(h.VariablesTF.Bytes())
+		b.tfvars.Write(h.TFVars.Bytes())
+		b.imports.Write(h.ImportScript.Bytes())
+	} else {
+		files[getHCLFileName(output)] = h.HCL.Bytes()
+		if h.VariablesTF.Len() > 0 {
+			files[filepath.Join(filepath.Dir(output), "variables.tf")] = h.VariablesTF.Bytes()
+			files[filepath.Join(filepath.Dir(output), "terraform.tfvars")] = h.TFVars.Bytes()
+		}
+	}
+	for file, data := range files {
+		if err := writeFile(file, data); err != nil {
+			return err
+		}
+	}
+	if h.ImportScript.Len() > 0 && !b.Combined {
+		script := append([]byte(generator.ImportScriptHeader), h.ImportScript.Bytes()...)
+		if err := writeScript(filepath.Join(filepath.Dir(output), "imports.sh"), script); err != nil {
+			return err
+		}
+	}
+	if differences > 0 {
+		return fmt.Errorf("verification failed: %d difference(s) found", differences)
+	}
+	return nil
+}
+
+// group groups the jobs with the same structure, as given by the fingerprint
+// of their configuration, preserving their order.
+func group(jobs []*job) [][]*job {
+	var groups [][]*job
+	indexes := map[string]int{}
+	for _, j := range jobs {
+		key := generator.Fingerprint(j.document)
+		if i, ok := indexes[key]; ok {
+			groups[i] = append(groups[i], j)
+		} else {
+			indexes[key] = len(groups)
+			groups = append(groups, []*job{j})
+		}
+	}
+	return groups
+}
+
+// relative returns the path of the given file relative to the root, or its
+// base name if it is not under the root.
+func (b *Batch) relative(path string) string {
+	root, err := filepath.Abs(b.Root)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	return relative
+}
+
+// findJobs returns the paths of the configuration files of all the jobs in
+// the given JENKINS_HOME or jobs directory, including those in folders (i.e.
+// all the jobs/<name>/config.xml files); the builds and workspaces of the jobs
+// are not searched, nor are the branches of multibranch projects, which are
+// generated by Jenkins.
+func findJobs(root string) ([]string, error) {
+	var paths []string
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if filepath.Base(filepath.Dir(path)) == "jobs" {
+				// a job, or a folder
+				return nil
+			}
+			switch info.Name() {
+			case "builds", "workspace", "branches", "modules":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == "config.xml" && filepath.Base(filepath.Dir(filepath.Dir(path))) == "jobs" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking %s: %v", root, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dihedron/jted/jenkins"
+)
+
+const (
+	fetchUsage = `
+usage:
+  $> jted fetch -url <url> [-user <user>] [-token <token>] [-output <directory>]
+                [-combined] [-group] [-verify] [<options>...] [<job>...]
+where:
+  -url <url>
+    specifies the base URL of the Jenkins server, e.g. https://jenkins.example.com/
+  -user <user>
+    specifies the user to authenticate as [default: $JENKINS_USER]
+  -token <token>
+    specifies the API token (or the password) of the user; prefer setting
+	it in the environment, so that it does not show in the process list
+	[default: $JENKINS_TOKEN]
+  -output <directory>
+    specifies the output directory, where the generated files are written
+	in the same layout as in JENKINS_HOME, i.e. jobs/<name>/config.xml.hcl
+	and jobs/<folder>/jobs/<name>/config.xml.hcl [default: current directory]
+  -combined, -group, -verify
+    as in recursive mode
+  options
+    the options controlling the generation, as in recursive mode (e.g.
+	-format, -naming, -rules...)
+  job [in]  is the full name (e.g. folder/name) of a job to fetch from the
+	server [default: all the jobs, including those in folders]
+`
+)
+
+// fetch implements the "fetch" subcommand: it pulls the config.xml of the jobs
+// from a Jenkins server through its REST API and processes them as in recursive
+// mode, without copying the files off the server first.
+func fetch(args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	url := flags.String("url", "", "the base URL of the Jenkins server")
+	user := flags.String("user", os.Getenv("JENKINS_USER"), "the user to authenticate as [default: $JENKINS_USER]")
+	token := flags.String("token", os.Getenv("JENKINS_TOKEN"), "the API token of the user [default: $JENKINS_TOKEN]")
+	output := flags.String("output", ".", "the output directory [default: current directory]")
+	combined := flags.Bool("combined", false, "write a single main.tf with all the jobs [default: false]")
+	grouped := flags.Bool("group", false, "share templates among the jobs with the same structure [default: false]")
+	verify := flags.Bool("verify", false, "check that the templates and parameters reproduce the original files [default: false]")
+	options := newOptions(flags)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, fetchUsage) }
+	flags.Parse(args)
+
+	if *url == "" {
+		flags.Usage()
+		os.Exit(1)
+	}
+	if *grouped && *options.variables {
+		return fmt.Errorf("variables cannot be generated for groups of jobs")
+	}
+	newHandler, err := options.factory("")
+	if err != nil {
+		return err
+	}
+	runBatch(&Batch{
+		Client:   jenkins.New(*url, *user, *token),
+		Names:    flags.Args(),
+		Output:   *output,
+		Combined: *combined,
+		Group:    *grouped,
+		Verify:   *verify,
+		Handler:  newHandler,
+	})
+	return nil
+}
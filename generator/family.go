@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// merge merges the XML trees of several documents of the same family into a
+// single tree, where each node records the values it has in every document;
+// the documents must have the same structure, that is the same elements (with
+// the same attributes) in the same positions, but they can have different
+// values.
+func merge(documents []*Node) (*Node, error) {
+	return mergeNodes("", documents)
+}
+
+// variable returns whether the given node may differ across documents.
+func variable(node *Node) bool {
+	switch node.xml.(type) {
+	case xml.Comment, xml.CharData:
+		return true
+	}
+	return false
+}
+
+func mergeNodes(path string, nodes []*Node) (*Node, error) {
+	first := nodes[0]
+	merged := &Node{
+		xml:        first.xml,
+		container:  first.container,
+		value:      first.value,
+		text:       first.text,
+		script:     first.script,
+		cdata:      first.cdata,
+		selections: first.selections,
+	}
+	element, ok := first.xml.(xml.StartElement)
+	if ok {
+		path = path + "/" + element.Name.Local
+		merged.attributes = make([][]string, len(element.Attr))
+	}
+	// comments and text chunks may differ across documents: the ones in the first
+	// one are kept
+	for i, node := range nodes {
+		if ok {
+			other, _ := node.xml.(xml.StartElement)
+			if other.Name.Local != element.Name.Local {
+				return nil, fmt.Errorf("document %d: element <%s> found at %s instead of <%s>", i+1, other.Name.Local, path, element.Name.Local)
+			}
+			if len(other.Attr) != len(element.Attr) {
+				return nil, fmt.Errorf("document %d: element %s has %d attributes instead of %d", i+1, path, len(other.Attr), len(element.Attr))
+			}
+			for j, attr := range other.Attr {
+				if attr.Name.Local != element.Attr[j].Name.Local {
+					return nil, fmt.Errorf("document %d: attribute %s found at %s instead of %s", i+1, attr.Name.Local, path, element.Attr[j].Name.Local)
+				}
+				merged.attributes[j] = append(merged.attributes[j], attr.Value)
+			}
+			merged.values = append(merged.values, node.value)
+			merged.positions = append(merged.positions, node.positions...)
+		} else if !variable(first) && fmt.Sprintf("%#v", node.xml) != fmt.Sprintf("%#v", first.xml) {
+			return nil, fmt.Errorf("document %d: different node found in %s", i+1, path)
+		}
+		if len(node.children) != len(first.children) {
+			return nil, fmt.Errorf("document %d: element %s has %d children instead of %d", i+1, path, len(node.children), len(first.children))
+		}
+	}
+	for i := range first.children {
+		children := make([]*Node, len(nodes))
+		for j, node := range nodes {
+			children[j] = node.children[i]
+		}
+		child, err := mergeNodes(path, children)
+		if err != nil {
+			return nil, err
+		}
+		merged.children = append(merged.children, child)
+	}
+	return merged, nil
+}
+
+// Fingerprint returns a digest of the structure of the given document, which
+// is the same for all the documents that can be merged into a family: it covers
+// the names of the elements and of their attributes, the position of comments
+// and the other nodes but not the values, apart from the plugins providing the
+// elements, without their versions (e.g. plugin="git@4.0.0" counts as git).
+func Fingerprint(document *Node) string {
+	var builder strings.Builder
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		switch element := node.xml.(type) {
+		case xml.StartElement:
+			builder.WriteString("<" + element.Name.Local)
+			for _, attr := range element.Attr {
+				builder.WriteString(" " + attr.Name.Local)
+				if attr.Name.Local == "plugin" {
+					builder.WriteString("=" + strings.SplitN(attr.Value, "@", 2)[0])
+				}
+			}
+			builder.WriteString(">")
+		case xml.Comment:
+			builder.WriteString("<!---->")
+		case xml.CharData:
+			builder.WriteString("#text")
+		default:
+			builder.WriteString(fmt.Sprintf("%#v", node.xml))
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+		builder.WriteString("/")
+	}
+	walk(document)
+	digest := sha1.Sum([]byte(builder.String()))
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,134 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dihedron/jted/hcl"
+)
+
+// Format defines the syntax of the generated HCL.
+type Format int
+
+const (
+	// LegacyFormat is the Terraform 0.11 (HCL1) syntax, with the template
+	// referenced as "file://<path>".
+	LegacyFormat Format = iota
+	// HCL2Format is the Terraform 0.12+ (HCL2) syntax, with the template
+	// referenced through the file() function.
+	HCL2Format
+)
+
+// ParseFormat returns the Format corresponding to the given string ("hcl1" or
+// "hcl2").
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(value) {
+	case "hcl1", "legacy":
+		return LegacyFormat, nil
+	case "hcl2":
+		return HCL2Format, nil
+	}
+	return LegacyFormat, fmt.Errorf("invalid output format: %q", value)
+}
+
+// String returns the string representation of the Format.
+func (f Format) String() string {
+	if f == HCL2Format {
+		return "hcl2"
+	}
+	return "hcl1"
+}
+
+// legacy returns whether the HCL is written in the legacy syntax; variables and
+// modules are always written in the HCL2 one.
+func (h *Handler) legacy() bool {
+	return h.Format == LegacyFormat && !h.Variables && h.Module == ""
+}
+
+// writeResourceHCL2 writes a jenkins_job resource with the given parameters in
+// HCL2 syntax; the job attributes (name, description...) are set to the given
+// values, or to placeholders if none is provided; any other given attribute
+// follows them.
+func (h *Handler) writeResourceHCL2(label string, attributes map[string]interface{}, parameters map[string]interface{}) {
+	body := hcl.NewBody()
+	for _, attribute := range []struct {
+		name        string
+		placeholder interface{}
+	}{
+		{"name", "<job name here>"},
+		{"display_name", "<[optional] job display name here>"},
+		{"description", "<job description here>"},
+		{"disabled", false},
+	} {
+		if value, ok := attributes[attribute.name]; ok {
+			body.Set(attribute.name, value)
+		} else {
+			body.Set(attribute.name, attribute.placeholder)
+		}
+	}
+	var others []string
+	for name := range attributes {
+		if _, ok := body.Attributes[name]; !ok {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		body.Set(name, attributes[name])
+	}
+	if len(parameters) > 0 {
+		body.Set("parameters", h.heredocs(parameters))
+	}
+	if h.EmbedConfigXML {
+		// config.xml template must be inlined
+		body.Set("template", hcl.Heredoc(h.ConfigXML.String()))
+	} else {
+		body.Set("template", &hcl.Call{
+			Name:      "file",
+			Arguments: []interface{}{hcl.Template(h.modulePath(h.TemplateFile))},
+		})
+	}
+	resource := hcl.NewBody()
+	resource.Blocks = append(resource.Blocks, &hcl.Block{
+		Comment: "Jenkins job definition",
+		Type:    "resource",
+		Labels:  []string{"jenkins_job", label},
+		Body:    body,
+	})
+	h.HCL.WriteString("\n")
+	hcl.Write(&h.HCL, resource)
+}
+
+// heredocs returns a copy of the given value where, in fidelity mode, multiline
+// strings are turned into heredocs, as long as they end with a newline (which
+// heredocs always do) and they are not list items (which heredocs cannot be,
+// since the closing marker must be alone on its line).
+func (h *Handler) heredocs(value interface{}) interface{} {
+	if !h.Fidelity {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			result[k] = h.heredocs(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			if _, ok := item.(map[string]interface{}); ok {
+				result[i] = h.heredocs(item)
+			} else {
+				result[i] = item
+			}
+		}
+		return result
+	case string:
+		if strings.Contains(strings.TrimSuffix(v, "\n"), "\n") && strings.HasSuffix(v, "\n") {
+			return hcl.Heredoc(v)
+		}
+	}
+	return value
+}
@@ -0,0 +1,91 @@
+// Package generator turns the config.xml of Jenkins jobs into config.xml
+// templates and the Terraform resources (jenkins_job) that render them, with
+// the values that vary from job to job extracted into a parameters map: the
+// Handler receives the events of the sax.Parser and, at the end of each
+// document (or of a family of documents with the same structure), generates
+// the template, the HCL and the related files into its buffers.
+package generator
+
+import (
+	"io"
+
+	"github.com/dihedron/jted/sax"
+	"github.com/dihedron/jted/stack"
+)
+
+// Options controls how templates and resources are generated.
+type Options struct {
+	IncludeEmptyValues bool                 // if even empty tags should be parameterised
+	EmbedConfigXML     bool                 // if the confg.xml template should be inlined
+	Naming             NamingMode           // how parameter names are derived from elements
+	Attributes         []string             // the attributes to parameterise ("*" for all)
+	Format             Format               // the syntax of the generated HCL
+	Variables          bool                 // if parameters should be exposed as Terraform variables
+	EscapeValues       bool                 // if all parameter values should be XML-escaped when rendered
+	Fidelity           bool                 // if text and whitespaces should be preserved exactly
+	Rules              *Rules               // the rules controlling which elements are parameterised, and how
+	ResourceAttributes []*ResourceAttribute // the elements mapped to resource attributes (default: from the rules, or DefaultResourceAttributes)
+	Names              []string             // the names of the jobs, one per document, if known
+	Prefix             string               // the prefix of the Terraform variables declared for the jobs
+	Module             string               // the directory of the Terraform module to generate, if any
+	Imports            ImportMode           // how the resources adopt the existing jobs, named after Names
+	TemplateFile       string               // the path of the template file, if not inlined
+}
+
+// Result holds what is generated for a document.
+type Result struct {
+	Template     string            // the config.xml template
+	HCL          string            // the jenkins_job resource (or the module's main.tf)
+	Parameters   []*Parameter      // the parameters, sorted by name
+	VariablesTF  string            // the variables declarations, if any
+	TFVars       string            // the variables values, if any
+	OutputsTF    string            // the module outputs, if any
+	README       string            // the module documentation, if any
+	ImportScript string            // the terraform import commands, if any
+	Scripts      map[string]string // the Pipeline scripts extracted from the document, by file name
+	Warnings     []string          // the warnings raised while processing the document
+	Withheld     []string          // the secrets withheld from the HCL, with the variables providing them
+}
+
+// NewHandler creates a new Handler with the given options.
+func NewHandler(options Options) *Handler {
+	return &Handler{
+		Options: options,
+		stack:   stack.New(),
+	}
+}
+
+// DefaultTemplateFile is the path of the template file referenced by the
+// resources generated by Generate, unless the options provide one.
+const DefaultTemplateFile = "config.xml.tpl"
+
+// Generate reads the config.xml of a job and generates its template and its
+// jenkins_job resource according to the given options.
+func Generate(reader io.Reader, options Options) (*Result, error) {
+	if options.TemplateFile == "" {
+		options.TemplateFile = DefaultTemplateFile
+	}
+	h := NewHandler(options)
+	parser := &sax.Parser{
+		EventHandler:   h,
+		ErrorHandler:   h,
+		LexicalHandler: h,
+	}
+	if err := parser.Parse(reader); err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Template:     h.ConfigXML.String(),
+		HCL:          h.HCL.String(),
+		Parameters:   h.Parameters[0],
+		VariablesTF:  h.VariablesTF.String(),
+		TFVars:       h.TFVars.String(),
+		OutputsTF:    h.OutputsTF.String(),
+		README:       h.README.String(),
+		ImportScript: h.ImportScript.String(),
+		Scripts:      h.Scripts,
+		Warnings:     h.Warnings,
+		Withheld:     h.Withheld,
+	}
+	return result, nil
+}
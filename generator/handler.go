@@ -0,0 +1,910 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dihedron/jted/hcl"
+	"github.com/dihedron/jted/sax"
+	"github.com/dihedron/jted/stack"
+)
+
+// Handler is an implementation of the sax.EventHandler, sax.ErrorHandler and
+// sax.LexicalHandler interfaces.
+type Handler struct {
+	Options
+	Deferred     bool                      // if generation is deferred until all documents are parsed
+	ConfigXML    bytes.Buffer              // the buffer where the config.xml template goes
+	HCL          bytes.Buffer              // the buffer where the HCL goes
+	VariablesTF  bytes.Buffer              // the buffer where the variables declarations go
+	TFVars       bytes.Buffer              // the buffer where the variables values go
+	OutputsTF    bytes.Buffer              // the buffer where the module outputs go
+	README       bytes.Buffer              // the buffer where the module documentation goes
+	ImportScript bytes.Buffer              // the buffer where the terraform import commands go
+	Parameters   [][]*Parameter            // the parameters written to the HCL, one list per document
+	Warnings     []string                  // the warnings raised while processing the document
+	Withheld     []string                  // the secrets withheld from the HCL, with the variables providing them
+	Scripts      map[string]string         // the Pipeline scripts extracted from the documents, by file name
+	Documents    []*Node                   // the documents parsed so far, if generation is deferred
+	stack        *stack.Stack              // the SAX internal stack
+	locator      *sax.Locator              // where the current event comes from in the document, if known
+	router       *sax.Router               // the router marking what the rules select, if any
+	document     *Node                     // the root of the XML tree
+	parameters   []map[string]interface{}  // where the parameters go, one map per document
+	resources    []map[string]interface{}  // the values of the special, top level parameters, one map per document
+	paths        map[string]string         // the path of the element owning each parameter
+	hints        map[string]*ParameterRule // the rules applying to each top level parameter
+	origins      map[string]*origin        // where the values of the top level parameters come from
+}
+
+// scope holds the parameters being collected for a portion of the template:
+// the whole document at the top level, or a single item in a range loop.
+type scope struct {
+	prefix     string                   // the prefix of parameter references (e.g. ".parameters.")
+	base       int                      // the depth of the stack where the scope begins
+	item       *Node                    // the repeated element, in range loops
+	scalar     bool                     // whether the item's value is used as is
+	escape     bool                     // whether all values are escaped, so that the items of a list render alike
+	value      interface{}              // the value of a scalar item
+	parameters []map[string]interface{} // where the parameters go, one map per document
+	owners     map[string]string        // the path of the element owning each parameter
+	warnings   []string                 // the warnings raised within the scope
+}
+
+// set sets the values of a parameter, one per document.
+func (s *scope) set(name string, values ...interface{}) {
+	for i, value := range values {
+		s.parameters[i][name] = value
+	}
+}
+
+// SetDocumentLocator records the locator, so that the position of each element
+// is known.
+func (h *Handler) SetDocumentLocator(locator *sax.Locator) {
+	h.locator = locator
+}
+
+// OnStartDocument clears all data structures and gets ready for parsing a new
+// XML document.
+func (h *Handler) OnStartDocument() error {
+	h.stack.Clear()
+	h.document = &Node{}
+	if h.Rules != nil {
+		h.router = h.route()
+		h.router.OnStartDocument()
+	}
+	if !h.Deferred {
+		h.Warnings = nil
+	}
+	return nil
+}
+
+// OnProcessingInstruction adds the processing instruction to the XML tree, so
+// it can be printed out as is.
+func (h *Handler) OnProcessingInstruction(element xml.ProcInst) error {
+	h.append(&Node{xml: element})
+	return nil
+}
+
+// OnStartElement adds the element to the XML tree and pushes it onto the stack;
+// if the element is not the first on the stack, its parent element, currently
+// at the top of the stack, is marked as a "container" so it can be treated
+// accordingly: it will never be collapsed to a <tag/> because it is not empty.
+func (h *Handler) OnStartElement(element xml.StartElement) error {
+	node := &Node{xml: element}
+	if h.locator != nil {
+		node.positions = []position{{h.locator.Line, h.locator.Column}}
+	}
+	if h.stack.Top() != nil {
+		node.script = isScript(h.stack.Top().(*Node), node)
+	}
+	h.append(node)
+	h.stack.Push(node)
+	if h.router != nil {
+		// the attributes selected by the rules are marked in the node
+		return h.router.OnStartElement(element)
+	}
+	return nil
+}
+
+// OnEndElement pops the current element off the stack.
+func (h *Handler) OnEndElement(element xml.EndElement) error {
+	if h.router != nil {
+		// the element is marked if the rules select it
+		if err := h.router.OnEndElement(element); err != nil {
+			return err
+		}
+	}
+	node := h.stack.Pop().(*Node)
+	if node.script || h.Fidelity {
+		node.value = node.text
+	} else {
+		node.value = strings.TrimSpace(node.text)
+	}
+	return nil
+}
+
+// OnCharacterData appends the text to the text of the current element, which
+// becomes its (trimmed) value when the element ends; in fidelity mode, and in
+// Pipeline scripts, the text is used as is, and in fidelity mode each chunk is
+// also added to the XML tree, so mixed content can be reproduced.
+func (h *Handler) OnCharacterData(element xml.CharData) error {
+	if h.stack.Top() == nil {
+		return nil
+	}
+	h.stack.Top().(*Node).text += string(element)
+	if h.Fidelity {
+		h.append(&Node{xml: element})
+	}
+	return nil
+}
+
+// OnComment adds the comment to the XML tree, so it can be printed out as is.
+func (h *Handler) OnComment(element xml.Comment) error {
+	h.append(&Node{xml: element})
+	return nil
+}
+
+// OnDirective adds the directive (e.g. <!DOCTYPE ...>) to the XML tree, so it
+// can be printed out as is.
+func (h *Handler) OnDirective(element xml.Directive) error {
+	h.append(&Node{xml: element})
+	return nil
+}
+
+// OnStartCDATA marks the current element as having its text in a CDATA section,
+// so the section can be reproduced in the template.
+func (h *Handler) OnStartCDATA() error {
+	if h.stack.Top() != nil {
+		h.stack.Top().(*Node).cdata = true
+	}
+	return nil
+}
+
+// OnEndCDATA is the default, do-nothing implementation of the corresponding
+// LexicalHandler interface.
+func (h *Handler) OnEndCDATA() error {
+	return nil
+}
+
+// OnEndDocument generates the config.xml template and the HCL with the
+// corresponding parameters out of the XML tree, unless generation is deferred,
+// in which case the tree is simply stored.
+func (h *Handler) OnEndDocument() error {
+	if h.Deferred {
+		h.Documents = append(h.Documents, h.document)
+		return nil
+	}
+	return h.Generate(h.document)
+}
+
+// Generate walks the XML trees of one or more documents and generates the
+// config.xml template and the HCL with the corresponding parameters; when
+// several documents are provided, they must have the same structure and
+// only the values that differ across them are turned into parameters, with
+// one jenkins_job resource per document.
+func (h *Handler) Generate(documents ...*Node) error {
+	for _, document := range documents {
+		if !rooted(document) {
+			return fmt.Errorf("no root element found")
+		}
+	}
+	document := documents[0]
+	if len(documents) > 1 {
+		var err error
+		if document, err = merge(documents); err != nil {
+			return err
+		}
+	}
+
+	h.parameters = make([]map[string]interface{}, len(documents))
+	h.resources = make([]map[string]interface{}, len(documents))
+	for i := range documents {
+		h.parameters[i] = map[string]interface{}{}
+		h.resources[i] = map[string]interface{}{}
+	}
+	top := &scope{
+		prefix:     ".parameters.",
+		parameters: h.parameters,
+		owners:     map[string]string{},
+	}
+	h.hints = map[string]*ParameterRule{}
+	h.origins = map[string]*origin{}
+	h.ConfigXML.Reset()
+	h.stack.Clear()
+	h.renderChildren(&h.ConfigXML, document, top)
+	h.Warnings = append(h.Warnings, top.warnings...)
+	h.paths = top.owners
+
+	if (h.Variables || h.Module != "") && len(documents) > 1 {
+		return fmt.Errorf("variables and modules cannot be generated for several documents")
+	}
+
+	h.HCL.Reset()
+	h.VariablesTF.Reset()
+	h.TFVars.Reset()
+	h.OutputsTF.Reset()
+	h.README.Reset()
+	h.ImportScript.Reset()
+	h.Parameters = nil
+	h.Withheld = nil
+	h.Scripts = map[string]string{}
+	hinted := make([]map[string]interface{}, len(documents))
+	for i := range documents {
+		var err error
+		if hinted[i], err = h.hint(h.parameters[i]); err != nil {
+			return err
+		}
+	}
+	if h.Module != "" {
+		h.writeModule(hinted[0], h.job(0))
+		return nil
+	}
+	variables := hcl.NewBody()
+	labels := map[string]bool{}
+	for i := range documents {
+		label, prefix := "<job name here>", h.Prefix
+		if len(documents) > 1 {
+			label, prefix = fmt.Sprintf("<job %d name here>", i+1), fmt.Sprintf("%sjob_%d_", h.Prefix, i+1)
+		}
+		attributes := h.job(i)
+		if name, ok := attributes["name"].(string); ok {
+			label = Label(name)
+			for j := 2; labels[label]; j++ {
+				label = fmt.Sprintf("%s_%d", Label(name), j)
+			}
+			labels[label] = true
+			if len(documents) > 1 {
+				prefix = h.Prefix + label + "_"
+			}
+		}
+		// secrets are never written to the HCL, sensitive variables provide them
+		parameters := h.withhold(variables, prefix, h.extract(i, len(documents), hinted[i]))
+		model := h.model(i, parameters)
+		h.Parameters = append(h.Parameters, model)
+		if h.Variables {
+			h.writeResourceHCL2(label, attributes, h.declareVariables(variables, model))
+		} else if h.Format == HCL2Format {
+			h.writeResourceHCL2(label, attributes, values(model))
+		} else {
+			h.writeResource(label, attributes, values(model))
+		}
+		if h.Imports != NoImports {
+			// the id of a job in the Jenkins provider is its full name
+			if i < len(h.Names) && h.Names[i] != "" {
+				h.writeImport(label, h.Names[i])
+			} else {
+				h.Warnings = append(h.Warnings, fmt.Sprintf("no import generated for resource %s, whose job name is unknown", label))
+			}
+		}
+	}
+	h.writeVariables(variables)
+	return nil
+}
+
+// writeResource writes a jenkins_job resource with the given parameters in
+// legacy HCL syntax; the job attributes (name, description...) are set to the
+// given values, or to placeholders if none is provided.
+func (h *Handler) writeResource(label string, attributes map[string]interface{}, parameters map[string]interface{}) {
+	h.HCL.WriteString(fmt.Sprintf(`
+/*
+ * Jenkins job definition
+ */
+resource "jenkins_job" %q {
+`, label))
+	values := map[string]interface{}{
+		"name":         "<job name here>",
+		"display_name": "<[optional] job display name here>",
+		"description":  "<job description here>",
+		"disabled":     false,
+	}
+	names := []string{"name", "display_name", "description", "disabled"}
+	var others []string
+	for name, value := range attributes {
+		if _, ok := values[name]; !ok {
+			others = append(others, name)
+		}
+		values[name] = value
+	}
+	sort.Strings(others)
+	for _, name := range append(names, others...) {
+		h.HCL.WriteString(fmt.Sprintf("    %-36s= ", name))
+		writeValue(&h.HCL, values[name], 1)
+		h.HCL.WriteString("\n")
+	}
+	if len(parameters) > 0 {
+		h.HCL.WriteString(fmt.Sprintf("\t%-36s= ", "parameters"))
+		writeValue(&h.HCL, parameters, 1)
+		h.HCL.WriteString("\n")
+	}
+	if h.EmbedConfigXML {
+		// config.xml template must be inlined
+		h.HCL.WriteString(fmt.Sprintf("\t%-40s=<<EOF\n", "template"))
+		h.HCL.WriteString(h.ConfigXML.String())
+		h.HCL.WriteString("EOF\n")
+	} else {
+		h.HCL.WriteString(fmt.Sprintf("\t%-36s= \"file://%s\"\n", "template", h.TemplateFile))
+	}
+	h.HCL.WriteString("}\n")
+}
+
+// OnError is the default implementation of the corresponding ErrorHandler
+// interface; it simply forwards any error to the Parser.
+func (h *Handler) OnError(err error) error {
+	return err
+}
+
+// append adds the given node to the children of the element at the top of the
+// stack (which becomes a "container", unless the node is a comment or text), or to the
+// document if the stack is empty.
+func (h *Handler) append(node *Node) {
+	parent := h.document
+	if h.stack.Top() != nil {
+		parent = h.stack.Top().(*Node)
+		switch node.xml.(type) {
+		case xml.Comment, xml.CharData:
+		default:
+			parent.container = true
+		}
+	}
+	parent.children = append(parent.children, node)
+}
+
+// render writes the template for the given node; elements are pushed onto the
+// stack while their subtree is being rendered so that their path is available.
+func (h *Handler) render(buffer *bytes.Buffer, node *Node, s *scope) {
+	switch element := node.xml.(type) {
+	case xml.ProcInst:
+		buffer.WriteString(fmt.Sprintf("<?%s %s?>\n", element.Target, literal(string(element.Inst))))
+	case xml.Directive:
+		buffer.WriteString(fmt.Sprintf("<!%s>\n", literal(string(element))))
+	case xml.Comment:
+		buffer.WriteString(fmt.Sprintf("%s<!--%s-->\n", tab(h.stack.Len()), literal(string(element))))
+	case xml.StartElement:
+		h.stack.Push(node)
+		defer h.stack.Pop()
+		indent := tab(h.stack.Len() - 1)
+		attributes := h.attributes(s)
+		// leaf writes the element with the given content, preceded by the comments
+		// it contains and in a CDATA section if the original text was; elements
+		// with no content at all are collapsed to <tag/>
+		leaf := func(content string) {
+			comments := h.comments(node)
+			if len(content) == 0 && len(comments) == 0 {
+				buffer.WriteString(fmt.Sprintf("%s<%s%s/>\n", indent, element.Name.Local, attributes))
+				return
+			}
+			if node.cdata {
+				content = "<![CDATA[" + content + "]]>"
+			}
+			buffer.WriteString(fmt.Sprintf("%s<%s%s>%s%s</%s>\n", indent, element.Name.Local, attributes, comments, content, element.Name.Local))
+		}
+		// text returns the given text as it must appear in the template
+		text := func(value string) string {
+			if node.cdata {
+				return literal(value)
+			}
+			return escape(value)
+		}
+		if node.container && len(strings.TrimSpace(node.text)) > 0 {
+			// mixed content cannot be parameterised: in fidelity mode it is kept
+			// as it is, otherwise its text is lost
+			if h.Fidelity {
+				s.warnings = append(s.warnings, fmt.Sprintf("element %s has mixed content, kept as it is", h.path("")))
+				buffer.WriteString(fmt.Sprintf("%s<%s%s>", indent, element.Name.Local, attributes))
+				for _, child := range node.children {
+					h.renderLiteral(buffer, child)
+				}
+				buffer.WriteString(fmt.Sprintf("</%s>\n", element.Name.Local))
+			} else {
+				s.warnings = append(s.warnings, fmt.Sprintf("element %s has mixed content, its text is lost (use fidelity mode to keep it)", h.path("")))
+				buffer.WriteString(fmt.Sprintf("%s<%s%s>\n", indent, element.Name.Local, attributes))
+				h.renderChildren(buffer, node, s)
+				buffer.WriteString(fmt.Sprintf("%s</%s>\n", indent, element.Name.Local))
+			}
+		} else if node.container {
+			// containers are always treated as <tag></tag> pairs and NEVER
+			// collapsed to <tag/>, which we only do for empty leaf tags.
+			buffer.WriteString(fmt.Sprintf("%s<%s%s>\n", indent, element.Name.Local, attributes))
+			h.renderChildren(buffer, node, s)
+			buffer.WriteString(fmt.Sprintf("%s</%s>\n", indent, element.Name.Local))
+		} else if len(node.value) > 0 && pattern.MatchString(node.value) {
+			// the value has already been parameterised "by hand"
+			leaf(h.parameterised(s, node.value, ""))
+		} else if node.script && len(node.value) > 0 {
+			// Pipeline scripts are kept as they are, whitespaces included, and
+			// escaped when the template is rendered (unless in a CDATA section)
+			if node.values != nil && constant(node.values) {
+				leaf(text(node.value))
+			} else {
+				values := node.values
+				if values == nil {
+					values = []string{node.value}
+				}
+				parameter := h.parameterName(s, "")
+				s.set(parameter, scripts(values)...)
+				if node.cdata {
+					leaf(fmt.Sprintf("{{ %s%s }}", s.prefix, parameter))
+				} else {
+					leaf(fmt.Sprintf("{{ html %s%s }}", s.prefix, parameter))
+				}
+			}
+		} else if node.values != nil && constant(node.values) && h.resourceAttribute(s) == nil && !isSecret(element.Name.Local, node.value) {
+			// in a family of documents, values that are the same everywhere are kept as they are
+			leaf(text(node.value))
+		} else if attribute := h.resourceAttribute(s); node.values != nil || h.isParameterisedValue(node) || attribute != nil {
+			// elements mapped to resource attributes are recorded even if empty,
+			// so that placeholders are only used for those that are missing
+			values := node.values
+			if values == nil {
+				values = []string{node.value}
+				if len(node.value) == 0 && attribute == nil {
+					values[0] = "<no value provided>"
+				}
+			}
+			var action string
+			if attribute != nil {
+				for i, value := range values {
+					h.resources[i][attribute.Attribute] = value
+				}
+			}
+			if attribute != nil && !attribute.Keep {
+				// if it is one of the "top level", special paramweters we do not prefix
+				// it with ".parameters" and we refer to it by the name of the corresponding
+				// jenkins_job resource attribute; being free text provided in the
+				// resource (e.g. the description), its value is always escaped
+				action = "." + attribute.Attribute
+				if !node.cdata {
+					action = "html " + action
+				}
+			} else if s.scalar && s.item == node {
+				// the item of a list of scalar values
+				action = h.action(s, ".", node.cdata, unsafe(values...) || hidden(element.Name.Local, values))
+				s.value = secrets(element.Name.Local, values)[0]
+			} else {
+				parameter := h.parameterName(s, "")
+				action = h.action(s, s.prefix+parameter, node.cdata, unsafe(values...) || hidden(element.Name.Local, values))
+				s.set(parameter, secrets(element.Name.Local, values)...)
+			}
+			leaf(fmt.Sprintf("{{- %s -}}", action))
+		} else {
+			leaf(text(node.value))
+		}
+	}
+}
+
+// action returns the template action printing the given reference, which is
+// XML-escaped when the template is rendered if escaping is forced (everywhere,
+// or in the scope) or needed, unless it goes into a CDATA section.
+func (h *Handler) action(s *scope, reference string, cdata bool, needed bool) string {
+	if !cdata && (h.EscapeValues || s.escape || needed) {
+		return "html " + reference
+	}
+	return reference
+}
+
+// noValue is the value of the parameters introduced "by hand", to be replaced
+// with the actual values; it renders as is, with no escaping.
+const noValue = "NO VALUE PROVIDED"
+
+// parameterised returns the template action for a value that has already been
+// parameterised "by hand" in the element at the top of the stack (or in one of
+// its attributes): a reference to a single parameter by name becomes a reference
+// to the template parameter with that name, which is added to the scope with no
+// value; any other action is kept as it is.
+func (h *Handler) parameterised(s *scope, value string, attribute string) string {
+	match := reference.FindStringSubmatch(value)
+	if match == nil {
+		return value
+	}
+	parameter := h.reserve(s, match[2], h.path(attribute))
+	if o, ok := h.origins[parameter]; ok && s.item == nil {
+		o.hand = value
+	}
+	values := make([]interface{}, len(s.parameters))
+	for i := range values {
+		values[i] = noValue
+	}
+	s.set(parameter, values...)
+	// the value is only known when the template is rendered, so it is escaped
+	// only if escaping is forced
+	return match[1] + h.action(s, s.prefix+parameter, false, false) + match[3]
+}
+
+// renderLiteral writes the given node as it is, with its attributes, text and
+// children, with no parameters.
+func (h *Handler) renderLiteral(buffer *bytes.Buffer, node *Node) {
+	switch element := node.xml.(type) {
+	case xml.CharData:
+		buffer.WriteString(escape(string(element)))
+	case xml.Comment:
+		buffer.WriteString(fmt.Sprintf("<!--%s-->", literal(string(element))))
+	case xml.StartElement:
+		buffer.WriteString("<" + element.Name.Local)
+		for _, attr := range element.Attr {
+			buffer.WriteString(fmt.Sprintf(" %s=\"%s\"", attr.Name.Local, escape(attr.Value)))
+		}
+		buffer.WriteString(">")
+		for _, child := range node.children {
+			h.renderLiteral(buffer, child)
+		}
+		buffer.WriteString("</" + element.Name.Local + ">")
+	}
+}
+
+// elements returns the children of the given node, except for text chunks.
+func elements(node *Node) []*Node {
+	var children []*Node
+	for _, child := range node.children {
+		if _, ok := child.xml.(xml.CharData); !ok {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// comments returns the comments contained in the given leaf element.
+func (h *Handler) comments(node *Node) string {
+	var buffer bytes.Buffer
+	for _, child := range node.children {
+		if comment, ok := child.xml.(xml.Comment); ok {
+			buffer.WriteString(fmt.Sprintf("<!--%s-->", literal(string(comment))))
+		}
+	}
+	return buffer.String()
+}
+
+// renderChildren writes the template for the children of the given node; runs
+// of repeated sibling elements sharing the same structure are rendered as a
+// single range loop over a list parameter.
+func (h *Handler) renderChildren(buffer *bytes.Buffer, node *Node, s *scope) {
+	children := elements(node)
+	for i := 0; i < len(children); {
+		j := i + 1
+		// documents in a family are merged by position, so there are no lists
+		if _, ok := children[i].xml.(xml.StartElement); ok && node.values == nil {
+			shape := h.shape(children[i])
+			for j < len(children) && h.shape(children[j]) == shape {
+				j++
+			}
+		}
+		if j-i < 2 || !h.renderRange(buffer, children[i:j], s) {
+			for _, child := range children[i:j] {
+				h.render(buffer, child, s)
+			}
+		}
+		i = j
+	}
+}
+
+// renderRange writes a range loop over the given repeated elements, and adds
+// the corresponding list parameter to the scope; if the elements cannot share
+// the same template, or there is nothing to parameterise, it returns false.
+func (h *Handler) renderRange(buffer *bytes.Buffer, items []*Node, s *scope) bool {
+	template, values, warnings, ok := h.renderItems(items, s.escape)
+	if !ok && !s.escape {
+		// the items whose values need escaping render differently from the
+		// others, unless the values of all of them are escaped
+		template, values, warnings, ok = h.renderItems(items, true)
+	}
+	if !ok {
+		return false
+	}
+
+	h.stack.Push(items[0])
+	parameter := h.name(s, "", pluralise(h.candidate(s, "")))
+	h.stack.Pop()
+	s.set(parameter, values)
+	s.warnings = append(s.warnings, warnings...)
+
+	buffer.WriteString(fmt.Sprintf("{{- range %s%s }}\n", s.prefix, parameter))
+	buffer.WriteString(template)
+	buffer.WriteString("{{- end }}\n")
+	return true
+}
+
+// renderItems renders each of the given repeated elements in its own scope,
+// where the values are escaped if so requested, and returns their template,
+// their values and the warnings raised; it fails if the items do not render
+// to the same template, or if there is nothing to parameterise in them.
+func (h *Handler) renderItems(items []*Node, escape bool) (string, []interface{}, []string, bool) {
+	var (
+		template string
+		values   []interface{}
+		warnings []string
+	)
+	for i, item := range items {
+		h.stack.Push(item)
+		scalar := !item.container && !h.hasParameterisedAttributes(item) && h.isParameterisedValue(item)
+		h.stack.Pop()
+		inner := &scope{
+			prefix:     ".",
+			base:       h.stack.Len(),
+			item:       item,
+			scalar:     scalar,
+			escape:     escape,
+			parameters: []map[string]interface{}{{}},
+			owners:     map[string]string{},
+		}
+		var b bytes.Buffer
+		h.render(&b, item, inner)
+		if i == 0 {
+			if !inner.scalar && len(inner.parameters[0]) == 0 {
+				return "", nil, nil, false
+			}
+			template = b.String()
+			warnings = inner.warnings
+		} else if b.String() != template {
+			return "", nil, nil, false
+		}
+		if inner.scalar {
+			values = append(values, inner.value)
+		} else {
+			values = append(values, inner.parameters[0])
+		}
+	}
+	return template, values, warnings, true
+}
+
+// shape returns a signature of the structure of the given node, which ignores
+// the values that would be turned into parameters: two elements with the same
+// shape can be rendered with the same template.
+func (h *Handler) shape(node *Node) string {
+	element, ok := node.xml.(xml.StartElement)
+	if !ok {
+		return fmt.Sprintf("%#v", node.xml)
+	}
+	h.stack.Push(node)
+	defer h.stack.Pop()
+	var buffer bytes.Buffer
+	buffer.WriteString("<" + element.Name.Local)
+	for _, attr := range element.Attr {
+		if h.isParameterised(attr) {
+			buffer.WriteString(fmt.Sprintf(" %s", attr.Name.Local))
+		} else {
+			buffer.WriteString(fmt.Sprintf(" %s=%q", attr.Name.Local, attr.Value))
+		}
+	}
+	buffer.WriteString(">")
+	if node.container {
+		for _, child := range elements(node) {
+			buffer.WriteString(h.shape(child))
+		}
+	} else if h.isParameterisedValue(node) {
+		buffer.WriteString("?")
+	} else {
+		buffer.WriteString(node.value)
+	}
+	buffer.WriteString("</>")
+	return buffer.String()
+}
+
+// attributes formats the attributes of the element at the top of the stack;
+// the values of the attributes selected for parameterisation are replaced by
+// references to template parameters, unless they have already been
+// parameterised "by hand".
+func (h *Handler) attributes(s *scope) string {
+	var buffer bytes.Buffer
+	node := h.stack.Top().(*Node)
+	for i, attr := range node.xml.(xml.StartElement).Attr {
+		if node.attributes != nil && !constant(node.attributes[i]) {
+			// in a family of documents, attributes that differ are always parameterised
+			parameter := h.parameterName(s, attr.Name.Local)
+			buffer.WriteString(fmt.Sprintf(" %s=\"{{ %s }}\"", attr.Name.Local, h.action(s, s.prefix+parameter, false, unsafe(node.attributes[i]...) || hidden(attr.Name.Local, node.attributes[i]))))
+			s.set(parameter, secrets(attr.Name.Local, node.attributes[i])...)
+		} else if h.isParameterised(attr) {
+			parameter := h.parameterName(s, attr.Name.Local)
+			buffer.WriteString(fmt.Sprintf(" %s=\"{{ %s }}\"", attr.Name.Local, h.action(s, s.prefix+parameter, false, unsafe(attr.Value) || hidden(attr.Name.Local, []string{attr.Value}))))
+			for i := range s.parameters {
+				s.parameters[i][parameter] = secrets(attr.Name.Local, []string{attr.Value})[0]
+			}
+		} else if pattern.MatchString(attr.Value) {
+			buffer.WriteString(fmt.Sprintf(" %s=\"%s\"", attr.Name.Local, h.parameterised(s, attr.Value, attr.Name.Local)))
+		} else {
+			buffer.WriteString(fmt.Sprintf(" %s=\"%s\"", attr.Name.Local, escape(attr.Value)))
+		}
+	}
+	return buffer.String()
+}
+
+// isParameterisedAttribute returns whether the values of the given attribute
+// should be turned into template parameters.
+func (h *Handler) isParameterisedAttribute(name string) bool {
+	for _, attribute := range h.Attributes {
+		if attribute == "*" || attribute == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isParameterised returns whether the value of the given attribute of the
+// element at the top of the stack should be turned into a template parameter,
+// because it was selected for that (and not excluded by the rules) or it is a
+// secret, unless it has already been parameterised "by hand".
+func (h *Handler) isParameterised(attr xml.Attr) bool {
+	if pattern.MatchString(attr.Value) {
+		return false
+	}
+	if isSecret(attr.Name.Local, attr.Value) {
+		return true
+	}
+	return (h.isParameterisedAttribute(attr.Name.Local) || h.included(attr.Name.Local)) && !h.excluded(attr.Name.Local)
+}
+
+// isParameterisedValue returns whether the value of the given leaf element,
+// at the top of the stack, should be turned into a template parameter: secrets
+// always are, values parameterised "by hand" never are, and the others are
+// unless excluded by the rules, if they are not empty (or empty values are
+// included, in general or by the rules).
+func (h *Handler) isParameterisedValue(node *Node) bool {
+	element := node.xml.(xml.StartElement)
+	if pattern.MatchString(node.value) {
+		return false
+	}
+	if isSecret(element.Name.Local, node.value) {
+		return true
+	}
+	if h.excluded("") {
+		return false
+	}
+	return len(node.value) > 0 || h.IncludeEmptyValues || h.included("")
+}
+
+// hasParameterisedAttributes returns whether any of the attributes of the given
+// node should be turned into template parameters.
+func (h *Handler) hasParameterisedAttributes(node *Node) bool {
+	for _, attr := range node.xml.(xml.StartElement).Attr {
+		if h.isParameterised(attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parameterName returns the name of the template parameter for the element at
+// the top of the stack (or for one of its attributes, if a non-empty attribute
+// name is provided), according to the naming mode, and reserves it within the
+// scope.
+func (h *Handler) parameterName(s *scope, attribute string) string {
+	return h.name(s, attribute, h.candidate(s, attribute))
+}
+
+// name reserves the given name for the parameter of the element at the top of
+// the stack (or of one of its attributes) within the scope, unless the rules
+// provide a different name; the rule applying to a top level parameter is
+// recorded so that it can be used when writing its value.
+func (h *Handler) name(s *scope, attribute string, candidate string) string {
+	rule := h.selected(attribute).parameter
+	if rule != nil && rule.Name != "" {
+		candidate = rule.Name
+	}
+	name := h.reserve(s, candidate, h.path(attribute))
+	if rule != nil && s.item == nil {
+		h.hints[name] = rule
+	}
+	return name
+}
+
+// candidate returns the name of the template parameter for the element at the
+// top of the stack (or for one of its attributes), according to the naming
+// mode; inside range loops the path is relative to the repeated element.
+func (h *Handler) candidate(s *scope, attribute string) string {
+	var tags []string
+	for _, node := range h.stack.Elements()[s.base:] {
+		tags = append(tags, node.(*Node).xml.(xml.StartElement).Name.Local)
+	}
+	var name string
+	if h.Naming == PathNaming {
+		name = qualify(tags)
+	} else {
+		name = templatise(tags[len(tags)-1])
+	}
+	if attribute != "" {
+		name += templatise(attribute)
+	}
+	return name
+}
+
+// path returns the path of the element at the top of the stack (or of one of
+// its attributes), e.g. /project/triggers/hudson.triggers.TimerTrigger/spec.
+func (h *Handler) path(attribute string) string {
+	var tags []string
+	for _, node := range h.stack.Elements() {
+		tags = append(tags, node.(*Node).xml.(xml.StartElement).Name.Local)
+	}
+	path := "/" + strings.Join(tags, "/")
+	if attribute != "" {
+		path += "/@" + attribute
+	}
+	return path
+}
+
+// reserve reserves the given parameter name for the element at the given path
+// and, for top level parameters, records where the element is.
+func (h *Handler) reserve(s *scope, name string, path string) string {
+	name = h.claim(s, name, path)
+	if s.item == nil {
+		// the top level parameters come from the element at the top of the stack
+		o := &origin{}
+		if top, ok := h.stack.Top().(*Node); ok {
+			o.positions = top.positions
+		}
+		h.origins[name] = o
+	}
+	return name
+}
+
+// claim claims the given parameter name for the element at the given path
+// within the scope; any collision with a parameter already used by a different
+// element is either resolved with a numeric suffix or reported as a warning.
+func (h *Handler) claim(s *scope, name string, path string) string {
+	owner, ok := s.owners[name]
+	if !ok {
+		s.owners[name] = path
+		return name
+	}
+	if h.Naming == LeafNaming {
+		s.warnings = append(s.warnings, fmt.Sprintf("parameter %s for %s overwrites the value of %s", name, path, owner))
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s%d", name, i)
+		if _, ok := s.owners[candidate]; !ok {
+			s.owners[candidate] = path
+			s.warnings = append(s.warnings, fmt.Sprintf("parameter %s for %s renamed to %s (already used by %s)", name, path, candidate, owner))
+			return candidate
+		}
+	}
+}
+
+// writeValue writes a parameter value in HCL format; lists and maps are written
+// recursively, with their entries indented one level deeper than the value.
+func writeValue(buffer *bytes.Buffer, value interface{}, depth int) {
+	indent := strings.Repeat("\t", depth)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		buffer.WriteString("{\n")
+		// sort keys to have parameters in alphabetical order
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buffer.WriteString(fmt.Sprintf("%s\t%-36s= ", indent, key(k)))
+			writeValue(buffer, v[k], depth+1)
+			buffer.WriteString(",\n")
+		}
+		buffer.WriteString(indent + "}")
+	case []interface{}:
+		buffer.WriteString("[\n")
+		for _, item := range v {
+			buffer.WriteString(indent + "\t")
+			writeValue(buffer, item, depth+1)
+			buffer.WriteString(",\n")
+		}
+		buffer.WriteString(indent + "]")
+	case hcl.Reference, *hcl.Call:
+		buffer.WriteString(fmt.Sprintf("\"${%s}\"", hcl.Format(v)))
+	case bool, int64, float64:
+		buffer.WriteString(fmt.Sprintf("%v", v))
+	case string:
+		buffer.WriteString(hcl.Quote(v))
+	}
+}
+
+// key returns the given map key, quoted if it is not a valid identifier (e.g.
+// the names of values that have been parameterised "by hand").
+func key(name string) string {
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '-'))) {
+			return hcl.Quote(name)
+		}
+	}
+	return name
+}
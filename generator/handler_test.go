@@ -0,0 +1,729 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dihedron/jted/hcl"
+	"github.com/dihedron/jted/sax"
+	"github.com/dihedron/jted/stack"
+)
+
+const collisions = `<?xml version='1.0' encoding='UTF-8'?>
+<project>
+  <triggers>
+    <hudson.triggers.TimerTrigger>
+      <spec>H 2 * * *</spec>
+    </hudson.triggers.TimerTrigger>
+    <hudson.triggers.SCMTrigger>
+      <spec>H/5 * * * *</spec>
+    </hudson.triggers.SCMTrigger>
+  </triggers>
+</project>
+`
+
+func parse(t *testing.T, handler *Handler, document string) {
+	handler.stack = stack.New()
+	parser := &sax.Parser{
+		EventHandler:   handler,
+		ErrorHandler:   handler,
+		LexicalHandler: handler,
+	}
+	if err := parser.Parse(strings.NewReader(document)); err != nil {
+		t.Fatalf("error parsing document: %v", err)
+	}
+}
+
+func TestNamingCollisions(t *testing.T) {
+	tests := []struct {
+		naming   NamingMode
+		names    []string
+		warnings int
+	}{
+		{LeafNaming, []string{"Spec"}, 1},
+		{UniqueNaming, []string{"Spec", "Spec2"}, 1},
+		{PathNaming, []string{"TriggersTimerTriggerSpec", "TriggersSCMTriggerSpec"}, 0},
+	}
+	for _, test := range tests {
+		handler := &Handler{Options: Options{Naming: test.naming}}
+		parse(t, handler, collisions)
+		if len(handler.parameters[0]) != len(test.names) {
+			t.Errorf("%v: invalid number of parameters: expected %d, got %d", test.naming, len(test.names), len(handler.parameters[0]))
+		}
+		for _, name := range test.names {
+			if _, ok := handler.parameters[0][name]; !ok {
+				t.Errorf("%v: parameter %s not found", test.naming, name)
+			}
+			if !strings.Contains(handler.ConfigXML.String(), "{{- .parameters."+name+" -}}") {
+				t.Errorf("%v: parameter %s not referenced in template", test.naming, name)
+			}
+		}
+		if len(handler.Warnings) != test.warnings {
+			t.Errorf("%v: invalid number of warnings: expected %d, got %d", test.naming, test.warnings, len(handler.Warnings))
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	handler := &Handler{Options: Options{Attributes: []string{"plugin"}}}
+	parse(t, handler, `<flow-definition plugin="workflow-job@2.10"><scm class="hudson.scm.NullSCM" plugin="{{ .parameters.Git }}"/></flow-definition>`)
+	if handler.parameters[0]["FlowDefinitionPlugin"] != "workflow-job@2.10" {
+		t.Errorf("invalid attribute parameter: expected workflow-job@2.10, got %q", handler.parameters[0]["FlowDefinitionPlugin"])
+	}
+	if len(handler.parameters[0]) != 2 || handler.parameters[0]["Git"] != noValue {
+		t.Errorf("invalid parameters: expected FlowDefinitionPlugin and Git, got %v", handler.parameters[0])
+	}
+	for _, expected := range []string{`plugin="{{ .parameters.FlowDefinitionPlugin }}"`, `class="hudson.scm.NullSCM"`, `plugin="{{ .parameters.Git }}"`} {
+		if !strings.Contains(handler.ConfigXML.String(), expected) {
+			t.Errorf("invalid template: %s not found", expected)
+		}
+	}
+}
+
+func TestRepeatedSiblings(t *testing.T) {
+	handler := &Handler{}
+	parse(t, handler, `<scm>
+  <branches>
+    <hudson.plugins.git.BranchSpec><name>*/master</name></hudson.plugins.git.BranchSpec>
+    <hudson.plugins.git.BranchSpec><name>*/develop</name></hudson.plugins.git.BranchSpec>
+  </branches>
+  <a><string>fast</string><string>slow</string></a>
+</scm>`)
+	branches, ok := handler.parameters[0]["HudsonPluginsGitBranchSpecs"].([]interface{})
+	if !ok || len(branches) != 2 {
+		t.Fatalf("invalid list parameter: %#v", handler.parameters[0]["HudsonPluginsGitBranchSpecs"])
+	}
+	if branches[1].(map[string]interface{})["Name"] != "*/develop" {
+		t.Errorf("invalid list item: expected */develop, got %v", branches[1])
+	}
+	choices, ok := handler.parameters[0]["Strings"].([]interface{})
+	if !ok || len(choices) != 2 || choices[0] != "fast" || choices[1] != "slow" {
+		t.Errorf("invalid list parameter: %#v", handler.parameters[0]["Strings"])
+	}
+	expected := `{{- range .parameters.HudsonPluginsGitBranchSpecs }}
+    <hudson.plugins.git.BranchSpec>
+      <name>{{- .Name -}}</name>
+    </hudson.plugins.git.BranchSpec>
+{{- end }}`
+	if !strings.Contains(handler.ConfigXML.String(), expected) {
+		t.Errorf("invalid template: range loop not found in\n%s", handler.ConfigXML.String())
+	}
+
+	// only one of the items has a value that needs escaping
+	document := `<parameterDefinitions>
+  <hudson.model.StringParameterDefinition>
+    <name>GREETING</name>
+    <defaultValue>say "hello"</defaultValue>
+  </hudson.model.StringParameterDefinition>
+  <hudson.model.StringParameterDefinition>
+    <name>TARGET</name>
+    <defaultValue>world</defaultValue>
+  </hudson.model.StringParameterDefinition>
+</parameterDefinitions>`
+	handler = &Handler{}
+	parse(t, handler, document)
+	definitions, ok := handler.parameters[0]["HudsonModelStringParameterDefinitions"].([]interface{})
+	if !ok || len(definitions) != 2 {
+		t.Fatalf("invalid list parameter: %#v", handler.parameters[0])
+	}
+	if !strings.Contains(handler.ConfigXML.String(), "<defaultValue>{{- html .DefaultValue -}}</defaultValue>") {
+		t.Errorf("invalid template: escaped range loop not found in\n%s", handler.ConfigXML.String())
+	}
+	differences, err := handler.Verify(0, []byte(document))
+	if err != nil || len(differences) > 0 {
+		t.Errorf("invalid template: %v %v", err, differences)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		naming      NamingMode
+		differences int
+	}{
+		{LeafNaming, 1},
+		{UniqueNaming, 0},
+	}
+	for _, test := range tests {
+		handler := &Handler{Options: Options{Naming: test.naming}}
+		parse(t, handler, collisions)
+		differences, err := handler.Verify(0, []byte(collisions))
+		if err != nil {
+			t.Fatalf("%v: error verifying template: %v", test.naming, err)
+		}
+		if len(differences) != test.differences {
+			t.Errorf("%v: invalid number of differences: expected %d, got %d (%v)", test.naming, test.differences, len(differences), differences)
+		}
+	}
+}
+
+func TestFamily(t *testing.T) {
+	handler := &Handler{Deferred: true}
+	parse(t, handler, `<project><keepDependencies>false</keepDependencies><scm plugin="git@3.3.0"><url>https://a</url></scm></project>`)
+	parse(t, handler, `<project>
+  <keepDependencies>false</keepDependencies>
+  <scm plugin="git@3.4.0"><url>https://b</url></scm>
+</project>`)
+	if err := handler.Generate(handler.Documents...); err != nil {
+		t.Fatalf("error generating template: %v", err)
+	}
+	for i, expected := range []string{"https://a", "https://b"} {
+		if len(handler.parameters[i]) != 2 || handler.parameters[i]["Url"] != expected {
+			t.Errorf("invalid parameters for document %d: %v", i, handler.parameters[i])
+		}
+	}
+	for i, expected := range []string{"1:76", "3:27"} {
+		url := handler.Parameters[i][1]
+		if url.Name != "Url" || url.Original != []string{"https://a", "https://b"}[i] || fmt.Sprintf("%d:%d", url.Line, url.Column) != expected {
+			t.Errorf("invalid parameter for document %d: %+v", i, url)
+		}
+	}
+	for _, expected := range []string{"<keepDependencies>false</keepDependencies>", `<scm plugin="{{ .parameters.ScmPlugin }}">`} {
+		if !strings.Contains(handler.ConfigXML.String(), expected) {
+			t.Errorf("invalid template: %s not found", expected)
+		}
+	}
+
+	parse(t, handler, `<project><keepDependencies>false</keepDependencies></project>`)
+	if err := handler.Generate(handler.Documents...); err == nil {
+		t.Errorf("documents with different structure should not be merged")
+	}
+}
+
+func TestVariables(t *testing.T) {
+	handler := &Handler{Options: Options{Variables: true}}
+	parse(t, handler, `<project><keepDependencies>false</keepDependencies><a><string>fast</string><string>slow</string></a></project>`)
+	for _, expected := range []string{
+		`variable "keep_dependencies" {`,
+		`type        = bool`,
+		`type        = list(string)`,
+	} {
+		if !strings.Contains(handler.VariablesTF.String(), expected) {
+			t.Errorf("invalid variables: %s not found in\n%s", expected, handler.VariablesTF.String())
+		}
+	}
+	if !strings.Contains(handler.TFVars.String(), "keep_dependencies = false") {
+		t.Errorf("invalid variables values:\n%s", handler.TFVars.String())
+	}
+	if !strings.Contains(handler.HCL.String(), "KeepDependencies = var.keep_dependencies") {
+		t.Errorf("invalid parameters: variable reference not found in\n%s", handler.HCL.String())
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	for _, test := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{"fast", "string"},
+		{true, "bool"},
+		{int64(5), "number"},
+		{1.5, "number"},
+		{[]interface{}{int64(1), 2.5}, "list(number)"},
+		{[]interface{}{"a", int64(1)}, "any"},
+	} {
+		if actual := hcl.Format(constraint(test.value)); actual != test.expected {
+			t.Errorf("invalid type constraint of %v: expected %s, got %s", test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestModule(t *testing.T) {
+	handler := &Handler{Options: Options{Module: "modules/example", TemplateFile: "modules/example/templates/config.xml.tpl"}}
+	parse(t, handler, `<project><description>A job</description><name>a name</name></project>`)
+	for _, expected := range []string{
+		`name         = var.name`,
+		`description  = var.description`,
+		`Name = var.name_2`,
+		`template = file("${path.module}/templates/config.xml.tpl")`,
+	} {
+		if !strings.Contains(handler.HCL.String(), expected) {
+			t.Errorf("invalid resource: %s not found in\n%s", expected, handler.HCL.String())
+		}
+	}
+	if !strings.Contains(handler.VariablesTF.String(), `default     = "A job"`) {
+		t.Errorf("invalid variables: description default not found in\n%s", handler.VariablesTF.String())
+	}
+	if !strings.Contains(handler.README.String(), "| name_2 | The value of /project/name in the original config.xml | `string` | `\"a name\"` | no |") {
+		t.Errorf("invalid documentation:\n%s", handler.README.String())
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	handler := &Handler{Options: Options{Variables: true}}
+	parse(t, handler, `<project>
+  <secretToken>{AQAAABAAAAAQwt1GRY9q3ZVQO3gt3epgTsk5dMX+jSacfO7NOzm5Eyk=}</secretToken>
+  <credentialsId>my-credentials</credentialsId>
+  <users>
+    <user><name>a</name><password>pa$$word</password></user>
+    <user><name>b</name><password>s3cr3t</password></user>
+  </users>
+</project>`)
+	if len(handler.Withheld) != 3 {
+		t.Errorf("invalid number of secrets: expected 3, got %d (%v)", len(handler.Withheld), handler.Withheld)
+	}
+	for _, output := range []string{handler.HCL.String(), handler.VariablesTF.String(), handler.TFVars.String()} {
+		for _, secret := range []string{"{AQAAAB", "pa$$word", "s3cr3t"} {
+			if strings.Contains(output, secret) {
+				t.Errorf("secret %s written in clear in\n%s", secret, output)
+			}
+		}
+	}
+	for _, expected := range []string{
+		"SecretToken   = var.secret_token",
+		"CredentialsId = var.credentials_id",
+		"Password = var.users_1_password",
+	} {
+		if !strings.Contains(handler.HCL.String(), expected) {
+			t.Errorf("invalid resource: %s not found in\n%s", expected, handler.HCL.String())
+		}
+	}
+	if !strings.Contains(handler.VariablesTF.String(), "sensitive   = true") {
+		t.Errorf("invalid variables: no sensitive variable in\n%s", handler.VariablesTF.String())
+	}
+	for _, expected := range []string{"{{- html .parameters.SecretToken -}}", "{{- html .Password -}}"} {
+		if !strings.Contains(handler.ConfigXML.String(), expected) {
+			t.Errorf("invalid template: secret not escaped, %s not found in\n%s", expected, handler.ConfigXML.String())
+		}
+	}
+	if strings.ContainsAny(handler.TFVars.String(), "<>&") {
+		t.Errorf("invalid values: placeholder not safe in XML in\n%s", handler.TFVars.String())
+	}
+	differences, err := handler.Verify(0, []byte(`<project><secretToken>{AQAAABAAAAAQwt1GRY9q3ZVQO3gt3epgTsk5dMX+jSacfO7NOzm5Eyk=}</secretToken><credentialsId>my-credentials</credentialsId><users><user><name>a</name><password>pa$$word</password></user><user><name>b</name><password>s3cr3t</password></user></users></project>`))
+	if err != nil || len(differences) > 0 {
+		t.Errorf("invalid template: %v %v", err, differences)
+	}
+}
+
+func TestWithholdOrder(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		handler := &Handler{Options: Options{Format: HCL2Format}}
+		handler.withhold(hcl.NewBody(), "", map[string]interface{}{
+			"Auth": map[string]interface{}{"Token": secret("a"), "Password": secret("b"), "Key": secret("c")},
+		})
+		expected := "Auth/Key (variable auth_key), Auth/Password (variable auth_password), Auth/Token (variable auth_token)"
+		if actual := strings.Join(handler.Withheld, ", "); actual != expected {
+			t.Fatalf("invalid secrets: expected %s, got %s", expected, actual)
+		}
+	}
+}
+
+func TestLegacySecrets(t *testing.T) {
+	handler := &Handler{Options: Options{Format: LegacyFormat}}
+	parse(t, handler, `<project><users><user><password>pa$$word</password></user></users></project>`)
+	if !strings.Contains(handler.HCL.String(), `"${var.password}"`) {
+		t.Errorf("invalid resource: secret reference not found in\n%s", handler.HCL.String())
+	}
+	if !strings.Contains(handler.VariablesTF.String(), `type        = "string"`) || strings.Contains(handler.VariablesTF.String(), "sensitive") {
+		t.Errorf("invalid variables: not in the legacy syntax in\n%s", handler.VariablesTF.String())
+	}
+}
+
+func TestLegacyQuoting(t *testing.T) {
+	handler := &Handler{Options: Options{Format: LegacyFormat}}
+	parse(t, handler, "<project><command>echo ${WORKSPACE} %{x}\x7f</command></project>")
+	if !strings.Contains(handler.HCL.String(), `"echo $${WORKSPACE} %%{x}\u007F"`) {
+		t.Errorf("invalid resource: value not quoted as HCL in\n%s", handler.HCL.String())
+	}
+}
+
+func TestScripts(t *testing.T) {
+	document := `<flow-definition>
+  <definition class="org.jenkinsci.plugins.workflow.cps.CpsFlowDefinition">
+    <script>node {
+    sh &quot;make &amp;&amp; make install&quot;
+}
+</script>
+  </definition>
+</flow-definition>`
+	handler := &Handler{Options: Options{Format: HCL2Format, TemplateFile: "jobs/config.xml.tpl"}}
+	parse(t, handler, document)
+	if handler.Scripts["jobs/config.groovy"] != "node {\n    sh \"make && make install\"\n}\n" {
+		t.Errorf("invalid script: %q", handler.Scripts["jobs/config.groovy"])
+	}
+	if !strings.Contains(handler.HCL.String(), `Script = file("${path.module}/config.groovy")`) {
+		t.Errorf("invalid parameters: script file not found in\n%s", handler.HCL.String())
+	}
+	differences, err := handler.Verify(0, []byte(document))
+	if err != nil || len(differences) > 0 {
+		t.Errorf("invalid template: %v %v", err, differences)
+	}
+}
+
+func TestLexical(t *testing.T) {
+	document := `<?xml version='1.1' encoding='UTF-8'?>
+<!DOCTYPE project>
+<project>
+  <!-- the triggers -->
+  <spec><!-- nightly -->H 2 * * *</spec>
+  <command><![CDATA[echo "a < b"]]></command>
+</project>
+`
+	handler := &Handler{}
+	parse(t, handler, document)
+	for _, expected := range []string{
+		"<?xml version='1.1' encoding='UTF-8'?>\n<!DOCTYPE project>\n<project>\n  <!-- the triggers -->\n",
+		"<spec><!-- nightly -->{{- .parameters.Spec -}}</spec>",
+		"<command><![CDATA[{{- .parameters.Command -}}]]></command>",
+	} {
+		if !strings.Contains(handler.ConfigXML.String(), expected) {
+			t.Errorf("invalid template: %q not found in\n%s", expected, handler.ConfigXML.String())
+		}
+	}
+	differences, err := handler.Verify(0, []byte(document))
+	if err != nil || len(differences) > 0 {
+		t.Errorf("invalid template: %v %v", err, differences)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	document := `<project>
+  <description>Build &amp; deploy</description>
+  <command>echo "{{ not a template }}" &gt; /dev/null</command>
+  <url>https://example.com/a?b=c&amp;d=e</url>
+  <branch>master</branch>
+  <filter>{{- BranchFilter -}}</filter>
+  <scm class="a &lt; b {{x}}" plugin="git"/>
+</project>`
+	handler := &Handler{}
+	parse(t, handler, document)
+	for _, expected := range []string{
+		`<description>{{- html .description -}}</description>`,
+		`<command>{{- html .parameters.Command -}}</command>`,
+		`<branch>{{- .parameters.Branch -}}</branch>`,
+		`<filter>{{- .parameters.BranchFilter -}}</filter>`,
+		`class="a &lt; b {{"{{"}}x{{"}}"}}"`,
+	} {
+		if !strings.Contains(handler.ConfigXML.String(), expected) {
+			t.Errorf("invalid template: %s not found in\n%s", expected, handler.ConfigXML.String())
+		}
+	}
+	differences, err := handler.Verify(0, []byte(document))
+	if err != nil || len(differences) != 0 {
+		t.Errorf("invalid template: %v %v", err, differences)
+	}
+
+	handler = &Handler{Options: Options{EscapeValues: true}}
+	parse(t, handler, document)
+	for _, expected := range []string{
+		`<branch>{{- html .parameters.Branch -}}</branch>`,
+		`<filter>{{- html .parameters.BranchFilter -}}</filter>`,
+	} {
+		if !strings.Contains(handler.ConfigXML.String(), expected) {
+			t.Errorf("invalid template: escaping not forced in\n%s", handler.ConfigXML.String())
+		}
+	}
+}
+
+func TestParameterised(t *testing.T) {
+	handler := &Handler{}
+	parse(t, handler, `<project>
+  <description>{{ .description }}</description>
+  <displayName>{{- .display_name -}}</displayName>
+  <spec>{{ .parameters.Spec }}</spec>
+</project>`)
+	for _, expected := range []string{
+		`<description>{{ .description }}</description>`,
+		`<displayName>{{- .display_name -}}</displayName>`,
+		`<spec>{{ .parameters.Spec }}</spec>`,
+	} {
+		if !strings.Contains(handler.ConfigXML.String(), expected) {
+			t.Errorf("invalid template: %s not found in\n%s", expected, handler.ConfigXML.String())
+		}
+	}
+	for _, p := range handler.Parameters[0] {
+		if p.Name != "Spec" {
+			t.Errorf("invalid parameters: unexpected parameter %s", p.Name)
+		}
+	}
+}
+
+func TestFidelity(t *testing.T) {
+	document := `<project>
+  <description>First line
+second line
+</description>
+  <prefix>  padded  </prefix>
+  <summary>Built with <b>care</b> &amp; love</summary>
+</project>`
+	handler := &Handler{Options: Options{Format: HCL2Format, Fidelity: true}}
+	parse(t, handler, document)
+	if handler.parameters[0]["Prefix"] != "  padded  " {
+		t.Errorf("invalid parameter: whitespaces not preserved in %q", handler.parameters[0]["Prefix"])
+	}
+	if handler.resources[0]["description"] != "First line\nsecond line\n" {
+		t.Errorf("invalid description: %q", handler.resources[0]["description"])
+	}
+	if !strings.Contains(handler.ConfigXML.String(), "<summary>Built with <b>care</b> &amp; love</summary>") {
+		t.Errorf("invalid template: mixed content not preserved in\n%s", handler.ConfigXML.String())
+	}
+	if len(handler.Warnings) != 1 {
+		t.Errorf("invalid number of warnings: expected 1, got %d (%v)", len(handler.Warnings), handler.Warnings)
+	}
+	differences, err := handler.Verify(0, []byte(document))
+	if err != nil || len(differences) != 0 {
+		t.Errorf("invalid template: %v %v", err, differences)
+	}
+
+	handler = &Handler{Options: Options{Format: HCL2Format, Fidelity: true}}
+	parse(t, handler, `<project><command>make
+make install
+</command></project>`)
+	if !strings.Contains(handler.HCL.String(), "Command = <<EOT\nmake\nmake install\nEOT\n") {
+		t.Errorf("invalid parameters: heredoc not found in\n%s", handler.HCL.String())
+	}
+}
+
+func TestRules(t *testing.T) {
+	file, err := ioutil.TempFile("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{
+  "include": ["//hudson.plugins.git.UserRemoteConfig/url"],
+  "exclude": ["/project/keepDependencies", "//scm"],
+  "parameters": [
+    {"select": "//userRemoteConfigs//url", "name": "RepositoryURL"},
+    {"select": "/project/quietPeriod", "type": "number"},
+    {"select": "//spec", "name": "Schedule", "default": "H 2 * * *"}
+  ]
+}`)
+	file.Close()
+	rules, err := LoadRules(file.Name())
+	if err != nil {
+		t.Fatalf("error loading rules: %v", err)
+	}
+
+	handler := &Handler{Options: Options{Format: HCL2Format, Rules: rules}}
+	parse(t, handler, `<project>
+  <keepDependencies>false</keepDependencies>
+  <quietPeriod>5</quietPeriod>
+  <scm class="hudson.plugins.git.GitSCM">
+    <userRemoteConfigs>
+      <hudson.plugins.git.UserRemoteConfig>
+        <url>https://example.com/repo.git</url>
+      </hudson.plugins.git.UserRemoteConfig>
+    </userRemoteConfigs>
+    <branch>master</branch>
+  </scm>
+  <triggers>
+    <hudson.triggers.TimerTrigger>
+      <spec>@daily</spec>
+    </hudson.triggers.TimerTrigger>
+  </triggers>
+</project>`)
+	template := handler.ConfigXML.String()
+	for _, expected := range []string{
+		"<keepDependencies>false</keepDependencies>",
+		"<branch>master</branch>",
+		"<url>{{- .parameters.RepositoryURL -}}</url>",
+		"<spec>{{- .parameters.Schedule -}}</spec>",
+	} {
+		if !strings.Contains(template, expected) {
+			t.Errorf("invalid template: %q not found in\n%s", expected, template)
+		}
+	}
+	for _, expected := range []string{
+		"QuietPeriod = 5 ",
+		"RepositoryURL = \"https://example.com/repo.git\" ",
+		"Schedule = \"H 2 * * *\" ",
+	} {
+		// ignore the alignment of the attributes
+		if !strings.Contains(strings.Join(strings.Fields(handler.HCL.String()), " "), expected) {
+			t.Errorf("invalid parameters: %q not found in\n%s", expected, handler.HCL.String())
+		}
+	}
+}
+
+func TestResourceAttributes(t *testing.T) {
+	mappings, err := ParseResourceAttributes("keepDependencies=keep_dependencies, disabled=disabled:keep")
+	if err != nil {
+		t.Fatalf("error parsing resource attributes: %v", err)
+	}
+	handler := &Handler{Options: Options{Format: HCL2Format, ResourceAttributes: mappings}}
+	parse(t, handler, `<project>
+  <description>My job</description>
+  <keepDependencies>false</keepDependencies>
+  <disabled>true</disabled>
+</project>`)
+	template := handler.ConfigXML.String()
+	for _, expected := range []string{
+		"<description>{{- .parameters.Description -}}</description>",
+		"<keepDependencies>{{- html .keep_dependencies -}}</keepDependencies>",
+		"<disabled>{{- .parameters.Disabled -}}</disabled>",
+	} {
+		if !strings.Contains(template, expected) {
+			t.Errorf("invalid template: %q not found in\n%s", expected, template)
+		}
+	}
+	if len(handler.parameters[0]) != 2 {
+		t.Errorf("invalid number of parameters: expected 2, got %d (%v)", len(handler.parameters[0]), handler.parameters[0])
+	}
+	if handler.resources[0]["keep_dependencies"] != "false" || handler.resources[0]["disabled"] != "true" {
+		t.Errorf("invalid resource attributes: %v", handler.resources[0])
+	}
+
+	for _, value := range []string{"description", "=description", "description=a-b", "template=template", "jobName=name"} {
+		if _, err := ParseResourceAttributes(value); err == nil {
+			t.Errorf("invalid resource attributes %q parsed", value)
+		}
+	}
+
+	// the template element is an ordinary parameter, not the resource template
+	document := `<project><template>base</template></project>`
+	handler = &Handler{Options: Options{Format: HCL2Format}}
+	parse(t, handler, document)
+	if handler.parameters[0]["Template"] != "base" {
+		t.Errorf("invalid parameters: template not found in %v", handler.parameters[0])
+	}
+	differences, err := handler.Verify(0, []byte(document))
+	if err != nil || len(differences) > 0 {
+		t.Errorf("invalid template: %v %v", err, differences)
+	}
+}
+
+func TestJobAttributes(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/var/lib/jenkins/jobs/my-app/config.xml":               "my-app",
+		"/var/lib/jenkins/jobs/team/jobs/my-app/config.xml":     "team/my-app",
+		"/var/lib/jenkins/jobs/a/jobs/b/jobs/my-app/config.xml": "a/b/my-app",
+		"/home/user/my-app.xml":                                 "my-app",
+		"/home/user/jobs/my-app/builds/config.xml":              "builds",
+	} {
+		if name := JobName(path); name != expected {
+			t.Errorf("invalid job name for %s: expected %q, got %q", path, expected, name)
+		}
+	}
+
+	for name, expected := range map[string]string{
+		"team/my-app": "team_my-app",
+		"run2":        "run2",
+		"r3":          "r3",
+		"a-b":         "a-b",
+		"a_b":         "a_b",
+		"MyApp":       "MyApp",
+		"2021 build":  "job_2021_build",
+		"-x":          "job_-x",
+	} {
+		if label := Label(name); label != expected {
+			t.Errorf("invalid label for %s: expected %q, got %q", name, expected, label)
+		}
+	}
+
+	handler := &Handler{Options: Options{Format: HCL2Format, Names: []string{"team/my-app"}}}
+	parse(t, handler, `<project>
+  <description>My ${job}</description>
+  <displayName>My App</displayName>
+  <disabled>true</disabled>
+</project>`)
+	for _, expected := range []string{
+		`resource "jenkins_job" "team_my-app" {`,
+		`name = "team/my-app"`,
+		`display_name = "My App"`,
+		`description = "My $${job}"`,
+		`disabled = true`,
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(handler.HCL.String()), " "), expected) {
+			t.Errorf("invalid resource: %q not found in\n%s", expected, handler.HCL.String())
+		}
+	}
+
+	// placeholders are only used for the elements that are missing, not for the empty ones
+	for _, format := range []Format{LegacyFormat, HCL2Format} {
+		handler = &Handler{Options: Options{Format: format}}
+		parse(t, handler, `<project><description></description></project>`)
+		for _, expected := range []string{
+			`description = ""`,
+			`display_name = "<[optional] job display name here>"`,
+		} {
+			if !strings.Contains(strings.Join(strings.Fields(handler.HCL.String()), " "), expected) {
+				t.Errorf("invalid resource: %q not found in\n%s", expected, handler.HCL.String())
+			}
+		}
+		if !strings.Contains(handler.ConfigXML.String(), "<description>{{- html .description -}}</description>") {
+			t.Errorf("invalid template: empty description not parameterised in\n%s", handler.ConfigXML.String())
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+	handler := &Handler{Options: Options{Format: HCL2Format, Imports: ImportBlocks, Names: []string{"team/my-app"}}}
+	parse(t, handler, `<project><description>My app</description></project>`)
+	if !strings.Contains(strings.Join(strings.Fields(handler.HCL.String()), " "), `import { to = jenkins_job.team_my-app id = "team/my-app" }`) {
+		t.Errorf("invalid import block in\n%s", handler.HCL.String())
+	}
+
+	handler = &Handler{Options: Options{Format: LegacyFormat, Imports: ImportScript, Names: []string{"team/it's"}}}
+	parse(t, handler, `<project><description>My app</description></project>`)
+	if script := handler.ImportScript.String(); script != `terraform import 'jenkins_job.team_it_s' 'team/it'\''s'`+"\n" {
+		t.Errorf("invalid import script: %s", script)
+	}
+	if strings.Contains(handler.HCL.String(), "import {") {
+		t.Errorf("unexpected import block in\n%s", handler.HCL.String())
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	result, err := Generate(strings.NewReader(`<project>
+  <description>My app</description>
+  <concurrentBuild>true</concurrentBuild>
+  <quietPeriod>007</quietPeriod>
+  <ratio>0.5</ratio>
+  <scm plugin="{{ .parameters.Git }}"/>
+  <builders>
+    <hudson.tasks.Shell>
+      <command>make</command>
+    </hudson.tasks.Shell>
+  </builders>
+  <password>secret</password>
+</project>`), Options{Format: HCL2Format, Names: []string{"my-app"}})
+	if err != nil {
+		t.Fatalf("error generating template: %v", err)
+	}
+	if !strings.Contains(result.Template, "{{- .parameters.Command -}}") || !strings.Contains(result.Template, "{{- html .description -}}") {
+		t.Errorf("invalid template:\n%s", result.Template)
+	}
+	if !strings.Contains(result.HCL, `resource "jenkins_job" "my-app" {`) {
+		t.Errorf("invalid HCL:\n%s", result.HCL)
+	}
+	var parameters []string
+	for _, p := range result.Parameters {
+		parameters = append(parameters, fmt.Sprintf("%s=%q:%v:%s@%d:%d:%t", p.Name, p.Original, p.Type, p.Path, p.Line, p.Column, p.Hand))
+	}
+	expected := []string{
+		`Command="make":string:/project/builders/hudson.tasks.Shell/command@9:7:false`,
+		`ConcurrentBuild="true":bool:/project/concurrentBuild@3:3:false`,
+		`Git="{{ .parameters.Git }}":string:/project/scm/@plugin@6:3:true`,
+		`Password="secret":secret:/project/password@12:3:false`,
+		`QuietPeriod="007":string:/project/quietPeriod@4:3:false`,
+		`Ratio="0.5":float:/project/ratio@5:3:false`,
+	}
+	if strings.Join(parameters, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("invalid parameters:\n%s", strings.Join(parameters, "\n"))
+	}
+	for _, p := range result.Parameters {
+		if p.Name == "Password" && p.Value != hcl.Reference("var.password") {
+			t.Errorf("secret not withheld: %v", p.Value)
+		}
+	}
+
+	if _, err := Generate(strings.NewReader(`<project>`), Options{}); err == nil {
+		t.Errorf("expected error on malformed document")
+	}
+	for _, document := range []string{"", "<?xml version='1.0' encoding='UTF-8'?>\n<!-- no job -->"} {
+		if _, err := Generate(strings.NewReader(document), Options{}); err == nil {
+			t.Errorf("expected error on document with no root element: %q", document)
+		}
+	}
+
+	result, err = Generate(strings.NewReader(`<flow-definition><definition class="org.jenkinsci.plugins.workflow.cps.CpsFlowDefinition"><script>node {}</script></definition></flow-definition>`), Options{Format: HCL2Format})
+	if err != nil {
+		t.Fatalf("error generating template: %v", err)
+	}
+	if !strings.Contains(result.HCL, `template = file("${path.module}/config.xml.tpl")`) || result.Scripts["config.groovy"] == "" {
+		t.Errorf("invalid default template file:\n%s %v", result.HCL, result.Scripts)
+	}
+}
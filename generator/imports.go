@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dihedron/jted/hcl"
+)
+
+// ImportMode defines how the generated resources adopt the existing jobs.
+type ImportMode int
+
+const (
+	// NoImports generates no imports: the jobs are created by Terraform.
+	NoImports ImportMode = iota
+	// ImportBlocks generates a Terraform 1.5+ import block next to each
+	// resource.
+	ImportBlocks
+	// ImportScript generates a shell script of terraform import commands.
+	ImportScript
+)
+
+// ParseImportMode returns the ImportMode corresponding to the given string
+// ("none", "blocks" or "script").
+func ParseImportMode(value string) (ImportMode, error) {
+	switch strings.ToLower(value) {
+	case "none", "":
+		return NoImports, nil
+	case "blocks", "block":
+		return ImportBlocks, nil
+	case "script":
+		return ImportScript, nil
+	}
+	return NoImports, fmt.Errorf("invalid import mode: %q", value)
+}
+
+// String returns the string representation of the ImportMode.
+func (m ImportMode) String() string {
+	switch m {
+	case ImportBlocks:
+		return "blocks"
+	case ImportScript:
+		return "script"
+	}
+	return "none"
+}
+
+// ImportScriptHeader is the beginning of the script of terraform import
+// commands.
+const ImportScriptHeader = `#!/bin/sh
+# Adopts the existing Jenkins jobs into the Terraform state; run it once, from
+# the directory of the Terraform configuration, after terraform init.
+set -e
+`
+
+// writeImport writes the import of the existing job with the given full name
+// (its id in the Jenkins provider) into the resource with the given label: an
+// import block after the resource, or a terraform import command in the
+// script.
+func (h *Handler) writeImport(label string, id string) {
+	address := "jenkins_job." + label
+	switch h.Imports {
+	case ImportBlocks:
+		body := hcl.NewBody()
+		body.Set("to", hcl.Reference(address))
+		body.Set("id", id)
+		block := hcl.NewBody()
+		block.Blocks = append(block.Blocks, &hcl.Block{
+			Type: "import",
+			Body: body,
+		})
+		h.HCL.WriteString("\n")
+		hcl.Write(&h.HCL, block)
+	case ImportScript:
+		h.ImportScript.WriteString(fmt.Sprintf("terraform import %s %s\n", quoteShell(address), quoteShell(id)))
+	}
+}
+
+// quoteShell quotes the given value for the POSIX shell.
+func quoteShell(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,167 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dihedron/jted/hcl"
+)
+
+// inputs are the jenkins_job resource attributes that are exposed as module
+// inputs, along with their description, type and default value (nil if the
+// input is required).
+var inputs = []struct {
+	name        string
+	description string
+	constraint  string
+	value       interface{}
+}{
+	{"name", "The name of the Jenkins job", "string", nil},
+	{"display_name", "The display name of the Jenkins job", "string", ""},
+	{"description", "The description of the Jenkins job", "string", ""},
+	{"disabled", "Whether the Jenkins job is disabled", "bool", false},
+}
+
+// outputs are the jenkins_job resource attributes that are exposed as module
+// outputs, along with their description.
+var outputs = []struct {
+	name        string
+	description string
+}{
+	{"id", "The ID of the Jenkins job"},
+	{"name", "The name of the Jenkins job"},
+}
+
+// writeModule writes the files of a reusable Terraform module wrapping a
+// jenkins_job resource: the resource goes into the HCL buffer (main.tf), the
+// job attributes and the parameters are declared as input variables in the
+// VariablesTF buffer (variables.tf), the resource attributes are exposed in
+// the OutputsTF buffer (outputs.tf) and the documentation goes into the README
+// buffer (README.md). The values found in the document are used as defaults;
+// the other resource attributes mapped from the document become inputs too.
+func (h *Handler) writeModule(parameters map[string]interface{}, resource map[string]interface{}) {
+	variables := hcl.NewBody()
+	attributes := map[string]interface{}{}
+	for _, input := range inputs {
+		body := hcl.NewBody()
+		body.Set("description", input.description)
+		body.Set("type", hcl.Reference(input.constraint))
+		value := input.value
+		if v, ok := resource[input.name]; ok {
+			value = v
+		}
+		if value != nil {
+			body.Set("default", value)
+		}
+		variables.Blocks = append(variables.Blocks, &hcl.Block{
+			Type:   "variable",
+			Labels: []string{input.name},
+			Body:   body,
+		})
+		attributes[input.name] = hcl.Reference("var." + input.name)
+	}
+	var others []string
+	for name := range resource {
+		if _, ok := attributes[name]; !ok {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		value := resource[name]
+		body := hcl.NewBody()
+		body.Set("description", fmt.Sprintf("The %s of the Jenkins job", strings.Replace(name, "_", " ", -1)))
+		body.Set("type", constraint(value))
+		body.Set("default", value)
+		variables.Blocks = append(variables.Blocks, &hcl.Block{
+			Type:   "variable",
+			Labels: []string{name},
+			Body:   body,
+		})
+		attributes[name] = hcl.Reference("var." + name)
+	}
+	model := h.model(0, h.withhold(variables, "", h.extract(0, 1, parameters)))
+	h.Parameters = [][]*Parameter{model}
+	references := h.declareVariables(variables, model)
+	hcl.Write(&h.VariablesTF, variables)
+
+	h.writeResourceHCL2("this", attributes, references)
+
+	values := hcl.NewBody()
+	for _, output := range outputs {
+		body := hcl.NewBody()
+		body.Set("description", output.description)
+		body.Set("value", hcl.Reference("jenkins_job.this."+output.name))
+		values.Blocks = append(values.Blocks, &hcl.Block{
+			Type:   "output",
+			Labels: []string{output.name},
+			Body:   body,
+		})
+	}
+	hcl.Write(&h.OutputsTF, values)
+
+	h.writeReadme(variables)
+}
+
+// writeReadme writes the documentation of the module into the README buffer,
+// with an example of use and the tables of inputs and outputs.
+func (h *Handler) writeReadme(variables *hcl.Body) {
+	name := filepath.Base(h.Module)
+
+	example := hcl.NewBody()
+	example.Set("source", "./"+name)
+	for _, block := range variables.Blocks {
+		if value, ok := block.Body.Attributes["default"]; ok {
+			example.Set(block.Labels[0], value)
+		} else if block.Body.Attributes["sensitive"] == true {
+			example.Set(block.Labels[0], secretPlaceholder)
+		} else {
+			example.Set(block.Labels[0], "<job name here>")
+		}
+	}
+	module := hcl.NewBody()
+	module.Blocks = append(module.Blocks, &hcl.Block{
+		Type:   "module",
+		Labels: []string{snake(templatise(name))},
+		Body:   example,
+	})
+	var usage strings.Builder
+	hcl.Write(&usage, module)
+
+	h.README.WriteString(fmt.Sprintf("# %s\n\n", name))
+	h.README.WriteString("Terraform module managing a Jenkins job through the `jenkins_job` resource;\n")
+	h.README.WriteString("the job configuration is rendered from `templates/config.xml.tpl`.\n\n")
+	h.README.WriteString("## Usage\n\n```hcl\n" + usage.String() + "```\n\n")
+	h.README.WriteString("## Inputs\n\n")
+	h.README.WriteString("| Name | Description | Type | Default | Required |\n")
+	h.README.WriteString("|------|-------------|------|---------|:--------:|\n")
+	for _, block := range variables.Blocks {
+		value, ok := block.Body.Attributes["default"]
+		def, required := "n/a", "yes"
+		if ok {
+			data, _ := json.Marshal(value)
+			def, required = "`"+string(data)+"`", "no"
+		}
+		h.README.WriteString(fmt.Sprintf("| %s | %s | `%s` | %s | %s |\n",
+			cell(block.Labels[0]),
+			cell(block.Body.Attributes["description"].(string)),
+			cell(hcl.Format(block.Body.Attributes["type"])),
+			cell(def),
+			required))
+	}
+	h.README.WriteString("\n## Outputs\n\n")
+	h.README.WriteString("| Name | Description |\n")
+	h.README.WriteString("|------|-------------|\n")
+	for _, output := range outputs {
+		h.README.WriteString(fmt.Sprintf("| %s | %s |\n", output.name, output.description))
+	}
+}
+
+// cell makes the given text fit into a Markdown table cell.
+func cell(text string) string {
+	text = strings.Replace(text, "|", "\\|", -1)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamingMode defines how the names of the template parameters are derived
+// from the elements in the XML document.
+type NamingMode int
+
+const (
+	// LeafNaming derives the parameter name from the leaf tag only (e.g. <spec>
+	// becomes Spec); different elements with the same tag end up sharing the
+	// same parameter and the last value wins.
+	LeafNaming NamingMode = iota
+	// PathNaming derives the parameter name from the path of the element in
+	// the document, excluding the root element (e.g. the <spec> in a GitLab
+	// push trigger becomes ...TriggersGitLabPushTriggerSpec).
+	PathNaming
+	// UniqueNaming derives the parameter name from the leaf tag and appends a
+	// numeric suffix whenever the name has already been taken by a different
+	// element (e.g. Spec, Spec2, Spec3...).
+	UniqueNaming
+)
+
+// ParseNamingMode returns the NamingMode corresponding to the given string
+// ("leaf", "path" or "unique").
+func ParseNamingMode(value string) (NamingMode, error) {
+	switch strings.ToLower(value) {
+	case "leaf":
+		return LeafNaming, nil
+	case "path":
+		return PathNaming, nil
+	case "unique":
+		return UniqueNaming, nil
+	}
+	return LeafNaming, fmt.Errorf("invalid naming mode: %q", value)
+}
+
+// String returns the string representation of the NamingMode.
+func (m NamingMode) String() string {
+	switch m {
+	case PathNaming:
+		return "path"
+	case UniqueNaming:
+		return "unique"
+	}
+	return "leaf"
+}
+
+// qualify returns the name of the template parameter for the element at the
+// given path, e.g. [flow-definition triggers com.acme.PushTrigger spec] becomes
+// TriggersPushTriggerSpec; the root element is never included, and dotted
+// (class) names are reduced to their last component.
+func qualify(path []string) string {
+	var buffer strings.Builder
+	for i, tag := range path {
+		if i == 0 && len(path) > 1 {
+			continue
+		}
+		if index := strings.LastIndex(tag, "."); index >= 0 && index < len(tag)-1 {
+			tag = tag[index+1:]
+		}
+		buffer.WriteString(templatise(tag))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of the value of a parameter.
+type Type int
+
+const (
+	// StringType is the type of text values.
+	StringType Type = iota
+	// BoolType is the type of true and false.
+	BoolType
+	// IntType is the type of integer numbers.
+	IntType
+	// FloatType is the type of decimal numbers.
+	FloatType
+	// ListType is the type of the values of repeated elements.
+	ListType
+	// MapType is the type of structured values.
+	MapType
+	// SecretType is the type of secrets, which are withheld from the HCL.
+	SecretType
+)
+
+// String returns the string representation of the Type.
+func (t Type) String() string {
+	switch t {
+	case BoolType:
+		return "bool"
+	case IntType:
+		return "int"
+	case FloatType:
+		return "float"
+	case ListType:
+		return "list"
+	case MapType:
+		return "map"
+	case SecretType:
+		return "secret"
+	}
+	return "string"
+}
+
+// Parameter is a parameter of the template, along with its value in one of the
+// documents and where it comes from; the HCL, the variables and the modules are
+// all written from the parameters.
+type Parameter struct {
+	Name     string      // the name of the parameter in the template
+	Original string      // the original text of the value (empty for lists and maps)
+	Value    interface{} // the value written to the HCL, typed, or the reference to the variable or file providing it
+	Type     Type        // the type of the value, as inferred from the original text or given by the rules
+	Path     string      // the path of the element (or attribute) in the document, e.g. /project/description
+	Line     int         // the line where the element begins in the document (0 if unknown)
+	Column   int         // the column where the element begins in the document (0 if unknown)
+	Hand     bool        // whether the value was parameterised by hand in the document
+}
+
+// position is where an element begins in a document.
+type position struct {
+	line   int
+	column int
+}
+
+// origin is where the value of a top level parameter comes from.
+type origin struct {
+	positions []position // where the element begins, in each document
+	hand      string     // the original action, if the value was parameterised by hand
+}
+
+// model returns the parameters of the document at the given index, sorted by
+// name, with the given values (as typed by the rules, or with their scripts
+// and secrets replaced).
+func (h *Handler) model(index int, values map[string]interface{}) []*Parameter {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parameters := make([]*Parameter, len(names))
+	for i, name := range names {
+		p := &Parameter{Name: name, Path: h.paths[name]}
+		switch original := h.parameters[index][name].(type) {
+		case script:
+			// scripts are written as they are, in their own files
+			p.Original, p.Value, p.Type = string(original), values[name], StringType
+		case secret:
+			p.Value, _ = infer(values[name])
+			p.Original, p.Type = string(original), SecretType
+		case string:
+			p.Value, p.Type = infer(values[name])
+			p.Original = original
+		default:
+			p.Value, p.Type = infer(values[name])
+		}
+		if o, ok := h.origins[name]; ok {
+			if index < len(o.positions) {
+				p.Line, p.Column = o.positions[index].line, o.positions[index].column
+			}
+			if o.hand != "" {
+				p.Original, p.Hand = o.hand, true
+			}
+		}
+		parameters[i] = p
+	}
+	return parameters
+}
+
+// values returns the values of the given parameters, by name.
+func values(parameters []*Parameter) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, p := range parameters {
+		result[p.Name] = p.Value
+	}
+	return result
+}
+
+// infer converts a value into a number or a boolean where this can be done
+// without altering its string representation (e.g. "007" and "t" are left
+// alone), recursively, and returns it along with its type; values typed by the
+// rules are returned as they are.
+func infer(value interface{}) (interface{}, Type) {
+	switch v := value.(type) {
+	case hinted:
+		switch v.value.(type) {
+		case bool:
+			return v.value, BoolType
+		case int64:
+			return v.value, IntType
+		case float64:
+			return v.value, FloatType
+		case []interface{}:
+			return v.value, ListType
+		case map[string]interface{}:
+			return v.value, MapType
+		}
+		return v.value, StringType
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			result[k], _ = infer(item)
+		}
+		return result, MapType
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i], _ = infer(item)
+		}
+		return result, ListType
+	case string:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil && strconv.FormatInt(i, 10) == v {
+			return i, IntType
+		} else if f, err := strconv.ParseFloat(v, 64); err == nil && strings.Contains(v, ".") && strconv.FormatFloat(f, 'f', -1, 64) == v {
+			return f, FloatType
+		} else if v == "true" || v == "false" {
+			return v == "true", BoolType
+		}
+	case secret:
+		return string(v), SecretType
+	case script:
+		return string(v), StringType
+	}
+	return value, StringType
+}
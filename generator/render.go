@@ -0,0 +1,38 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// Job holds the attributes of a jenkins_job resource that are used to render
+// its config.xml template.
+type Job struct {
+	Attributes map[string]interface{} // the resource attributes (name, parameters...)
+	Template   string                 // the text of the config.xml template
+}
+
+// Render applies the job attributes to the config.xml template; the template
+// can access the parameters map as .parameters and the other resource
+// attributes (e.g. .description, .display_name, .disabled) by name.
+func (j *Job) Render() ([]byte, error) {
+	t, err := template.New("config.xml").Parse(j.Template)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+	data := map[string]interface{}{}
+	for k, v := range j.Attributes {
+		if k != "template" {
+			data[k] = v
+		}
+	}
+	if _, ok := data["parameters"]; !ok {
+		data["parameters"] = map[string]interface{}{}
+	}
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, data); err != nil {
+		return nil, fmt.Errorf("error rendering template: %v", err)
+	}
+	return buffer.Bytes(), nil
+}
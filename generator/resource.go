@@ -0,0 +1,159 @@
+package generator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ResourceAttribute maps a child of the root element of the document (e.g.
+// <description>) to a top level attribute of the jenkins_job resource (e.g.
+// description), instead of a parameter.
+type ResourceAttribute struct {
+	Tag       string `json:"tag"`            // the name of the element
+	Attribute string `json:"attribute"`      // the name of the resource attribute
+	Keep      bool   `json:"keep,omitempty"` // if the value is kept in the parameters map, rather than moved into the resource
+}
+
+// DefaultResourceAttributes are the elements mapped to the attributes of the
+// jenkins_job resource, unless configured otherwise. The name is not among
+// them because it is (or should) never be in the config.xml and it is usually
+// sent to the server in the POST request; it appears in some configuration
+// tags though, so it must be treated as an ordinary parameter.
+var DefaultResourceAttributes = []*ResourceAttribute{
+	{Tag: "displayName", Attribute: "display_name"},
+	{Tag: "disabled", Attribute: "disabled"},
+	{Tag: "description", Attribute: "description"},
+}
+
+// ParseResourceAttributes parses a comma-separated list of mappings between
+// elements and resource attributes, such as "description=description"; the
+// ":keep" suffix (e.g. "disabled=disabled:keep") keeps the value in the
+// parameters map too, and "none" disables all mappings.
+func ParseResourceAttributes(value string) ([]*ResourceAttribute, error) {
+	result := []*ResourceAttribute{}
+	if strings.TrimSpace(value) == "none" {
+		return result, nil
+	}
+	for _, mapping := range split(value) {
+		pair := strings.SplitN(mapping, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid resource attribute %q: expected <tag>=<attribute>[:keep]", mapping)
+		}
+		attribute := &ResourceAttribute{Tag: strings.TrimSpace(pair[0]), Attribute: strings.TrimSpace(pair[1])}
+		if strings.HasSuffix(attribute.Attribute, ":keep") {
+			attribute.Attribute, attribute.Keep = strings.TrimSuffix(attribute.Attribute, ":keep"), true
+		}
+		if err := attribute.validate(); err != nil {
+			return nil, err
+		}
+		result = append(result, attribute)
+	}
+	return result, nil
+}
+
+// validate checks that the mapping has a tag and a valid attribute name, other
+// than the template and the name of the job, which are never taken from the
+// document.
+func (a *ResourceAttribute) validate() error {
+	if a.Tag == "" {
+		return fmt.Errorf("invalid resource attribute %q: missing tag", a.Attribute)
+	}
+	if !identifier.MatchString(a.Attribute) {
+		return fmt.Errorf("invalid resource attribute %q for tag %s", a.Attribute, a.Tag)
+	}
+	if a.Attribute == "template" || a.Attribute == "name" {
+		return fmt.Errorf("invalid resource attribute %q for tag %s: reserved attribute", a.Attribute, a.Tag)
+	}
+	return nil
+}
+
+// resourceAttributes returns the mappings between elements and resource
+// attributes in use: the handler's, those in the rules, or the default ones.
+func (h *Handler) resourceAttributes() []*ResourceAttribute {
+	if h.ResourceAttributes != nil {
+		return h.ResourceAttributes
+	}
+	if h.Rules != nil && h.Rules.ResourceAttributes != nil {
+		return h.Rules.ResourceAttributes
+	}
+	return DefaultResourceAttributes
+}
+
+// resourceAttribute returns the mapping to a resource attribute of the element
+// at the top of the stack, if any; only the children of the root element are
+// considered, since <description> and the like can also appear deeper in the
+// document with a different meaning (e.g. in build parameter definitions).
+func (h *Handler) resourceAttribute(s *scope) *ResourceAttribute {
+	if s.item != nil || h.stack.Len() != 2 {
+		return nil
+	}
+	name := templatise(h.stack.Top().(*Node).xml.(xml.StartElement).Name.Local)
+	for _, attribute := range h.resourceAttributes() {
+		if templatise(attribute.Tag) == name {
+			return attribute
+		}
+	}
+	return nil
+}
+
+// job returns the attributes of the jenkins_job resource for the document at
+// the given index: the name of the job, if known, and the values of the
+// elements mapped to resource attributes, where booleans are typed as such.
+func (h *Handler) job(index int) map[string]interface{} {
+	attributes := map[string]interface{}{}
+	if index < len(h.Names) && h.Names[index] != "" {
+		attributes["name"] = h.Names[index]
+	}
+	for name, value := range h.resources[index] {
+		if text, ok := value.(string); ok && (text == "true" || text == "false") {
+			attributes[name] = text == "true"
+		} else {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
+
+// invalid matches the characters that cannot appear in Terraform identifiers.
+var invalid = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Label returns the label of the jenkins_job resource of the job with the given
+// full name, where the characters that are not valid in identifiers are replaced
+// by underscores, e.g. team_my-app for team/my-app; labels that do not start with
+// a letter or an underscore are prefixed with job_, e.g. job_2021-release.
+func Label(name string) string {
+	label := invalid.ReplaceAllString(name, "_")
+	if label == "" || !(label[0] == '_' || unicode.IsLetter(rune(label[0]))) {
+		label = "job_" + label
+	}
+	return label
+}
+
+// JobName returns the full name of the Jenkins job whose configuration is in
+// the given file: Jenkins stores each job in jobs/<name>/config.xml, and the
+// jobs in a folder under jobs/<folder>/jobs/<name>/config.xml, so the job is
+// named after the directory (and the enclosing folders); any other file is
+// named after the job, e.g. my-job.xml.
+func JobName(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if filepath.Base(path) != "config.xml" {
+		return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	dir := filepath.Dir(path)
+	name := filepath.Base(dir)
+	for parent := filepath.Dir(dir); filepath.Base(parent) == "jobs"; {
+		folder := filepath.Dir(parent)
+		if filepath.Base(filepath.Dir(folder)) != "jobs" {
+			break
+		}
+		name = filepath.Base(folder) + "/" + name
+		parent = filepath.Dir(folder)
+	}
+	return name
+}
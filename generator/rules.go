@@ -0,0 +1,253 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/dihedron/jted/sax"
+)
+
+// Rules control which elements and attributes of the document are turned into
+// template parameters and how; they are read from a JSON file like this:
+//
+//	{
+//	  "include": ["//hudson.plugins.git.UserRemoteConfig/url", "//@plugin"],
+//	  "exclude": ["/project/keepDependencies", "//scm[@class='hudson.scm.NullSCM']"],
+//	  "parameters": [
+//	    {"select": "//userRemoteConfigs//url", "name": "RepositoryURL", "type": "string"}
+//	  ],
+//	  "resource": [
+//	    {"tag": "description", "attribute": "description"},
+//	    {"tag": "disabled", "attribute": "disabled", "keep": true}
+//	  ]
+//	}
+//
+// Selectors are XPath-like expressions, as supported by sax.Path.
+type Rules struct {
+	Include            []string             `json:"include,omitempty"`    // the elements and attributes to parameterise, even if empty or not selected with -parameterise-attributes
+	Exclude            []string             `json:"exclude,omitempty"`    // the elements (with their contents) and attributes to keep as they are
+	Parameters         []*ParameterRule     `json:"parameters,omitempty"` // how the selected parameters are named, typed and valued
+	ResourceAttributes []*ResourceAttribute `json:"resource,omitempty"`   // the elements mapped to resource attributes
+	include            []*sax.Path
+	exclude            []*sax.Path
+}
+
+// ParameterRule describes how the parameters selected by a rule are named,
+// typed and valued in the HCL.
+type ParameterRule struct {
+	Select  string      `json:"select"`            // the selector of the elements or attributes
+	Name    string      `json:"name,omitempty"`    // the name of the parameter, overriding the naming mode
+	Type    string      `json:"type,omitempty"`    // the type of the value in the HCL: string, number or bool
+	Default interface{} `json:"default,omitempty"` // the value written in the HCL instead of the one in the document
+	path    *sax.Path
+}
+
+// LoadRules reads the rules from the given JSON file.
+func LoadRules(name string) (*Rules, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("error opening rules file: %v", err)
+	}
+	defer file.Close()
+	rules := &Rules{}
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(rules); err != nil {
+		return nil, fmt.Errorf("error parsing rules file: %v", err)
+	}
+	for _, s := range rules.Include {
+		path, err := sax.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		rules.include = append(rules.include, path)
+	}
+	for _, s := range rules.Exclude {
+		path, err := sax.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		rules.exclude = append(rules.exclude, path)
+	}
+	for _, parameter := range rules.Parameters {
+		if parameter.path, err = sax.Compile(parameter.Select); err != nil {
+			return nil, err
+		}
+		switch parameter.Type {
+		case "", "string", "number", "bool":
+		default:
+			return nil, fmt.Errorf("invalid type %q for %s: expected string, number or bool", parameter.Type, parameter.Select)
+		}
+		if parameter.Name != "" && !identifier.MatchString(parameter.Name) {
+			return nil, fmt.Errorf("invalid parameter name %q for %s", parameter.Name, parameter.Select)
+		}
+	}
+	for _, attribute := range rules.ResourceAttributes {
+		if err := attribute.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// selection is what the rules select in an element, or in one of its
+// attributes, as marked while the document is parsed.
+type selection struct {
+	included  bool           // whether it is explicitly selected for parameterisation
+	excluded  bool           // whether it (with its contents) must be kept as it is
+	parameter *ParameterRule // the first parameter rule selecting it, if any
+}
+
+// route returns a Router marking the elements and attributes selected by the
+// rules in the nodes of the XML tree while the document is parsed: the node
+// being parsed is the one at the top of the stack when the Router invokes the
+// callbacks.
+func (h *Handler) route() *sax.Router {
+	router := sax.NewRouter(nil)
+	mark := func(match *sax.Match) *selection {
+		node := h.stack.Top().(*Node)
+		name := ""
+		if match.Attribute != nil {
+			name = match.Attribute.Name.Local
+		}
+		if node.selections == nil {
+			node.selections = map[string]*selection{}
+		}
+		if _, ok := node.selections[name]; !ok {
+			node.selections[name] = &selection{}
+		}
+		return node.selections[name]
+	}
+	for _, path := range h.Rules.include {
+		router.Route(path, func(match *sax.Match) error {
+			mark(match).included = true
+			return nil
+		})
+	}
+	for _, path := range h.Rules.exclude {
+		router.Route(path, func(match *sax.Match) error {
+			mark(match).excluded = true
+			return nil
+		})
+	}
+	for _, parameter := range h.Rules.Parameters {
+		if parameter.path == nil {
+			continue
+		}
+		parameter := parameter
+		router.Route(parameter.path, func(match *sax.Match) error {
+			if s := mark(match); s.parameter == nil {
+				s.parameter = parameter
+			}
+			return nil
+		})
+	}
+	return router
+}
+
+// selected returns what the rules select in the element at the top of the
+// stack, or in one of its attributes if a non-empty name is provided.
+func (h *Handler) selected(attribute string) selection {
+	if s, ok := h.stack.Top().(*Node).selections[attribute]; ok {
+		return *s
+	}
+	return selection{}
+}
+
+// included returns whether the element at the top of the stack (or one of its
+// attributes) is explicitly selected for parameterisation by the rules.
+func (h *Handler) included(attribute string) bool {
+	return h.selected(attribute).included
+}
+
+// excluded returns whether the element at the top of the stack (or one of its
+// attributes) must be kept as it is, because it or one of its ancestors is
+// excluded from parameterisation by the rules; explicit inclusions take
+// precedence, so that broad exclusions can be refined.
+func (h *Handler) excluded(attribute string) bool {
+	if h.included(attribute) {
+		return false
+	}
+	if h.selected(attribute).excluded {
+		return true
+	}
+	for _, node := range h.stack.Elements() {
+		if s, ok := node.(*Node).selections[""]; ok && s.excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the value of a parameter as it must be written in the HCL,
+// according to the rule's type and default value.
+func (p *ParameterRule) value(value interface{}) (interface{}, error) {
+	if p.Default != nil {
+		value = p.Default
+		if f, ok := value.(float64); ok && f == math.Trunc(f) {
+			// JSON numbers are always decoded as float64
+			value = int64(f)
+		}
+	}
+	text := fmt.Sprintf("%v", value)
+	switch p.Type {
+	case "string":
+		return text, nil
+	case "number":
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q of %s is not a number", text, p.Select)
+		}
+		return f, nil
+	case "bool":
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("value %q of %s is not a boolean", text, p.Select)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+// hinted is the value of a parameter as typed (and defaulted) by a rule, which
+// is written in the HCL as it is.
+type hinted struct {
+	value interface{}
+}
+
+// hint returns a copy of the given parameters where the values of the top level
+// parameters selected by rules are typed and defaulted accordingly; secrets are
+// only ever replaced by their defaults.
+func (h *Handler) hint(parameters map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for name, value := range parameters {
+		result[name] = value
+		rule, ok := h.hints[name]
+		if !ok || (rule.Type == "" && rule.Default == nil) {
+			continue
+		}
+		if _, ok := value.(secret); ok && rule.Default == nil {
+			continue
+		}
+		if _, ok := value.([]interface{}); ok && rule.Default == nil {
+			// lists of repeated elements can only be replaced
+			continue
+		}
+		v, err := rule.value(value)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = hinted{v}
+	}
+	return result, nil
+}
+
+// identifier matches valid parameter names.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
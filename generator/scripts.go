@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dihedron/jted/hcl"
+)
+
+// script is the value of a parameter holding a Pipeline script: it is used as
+// is when rendering the template, but it is written to its own file.
+type script string
+
+// isScript returns whether the given node is the <script> of a Pipeline job
+// defined in the job itself, i.e. with a CpsFlowDefinition.
+func isScript(parent *Node, node *Node) bool {
+	element, ok := node.xml.(xml.StartElement)
+	if !ok || element.Name.Local != "script" {
+		return false
+	}
+	definition, ok := parent.xml.(xml.StartElement)
+	if !ok || definition.Name.Local != "definition" {
+		return false
+	}
+	for _, attr := range definition.Attr {
+		if attr.Name.Local == "class" && strings.HasSuffix(attr.Value, "CpsFlowDefinition") {
+			return true
+		}
+	}
+	return false
+}
+
+// scripts returns the given values as Pipeline scripts.
+func scripts(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = script(value)
+	}
+	return result
+}
+
+// extract returns a copy of the given parameters, for the document at the given
+// index out of count, where each Pipeline script is replaced by a call to the
+// file() function on a .groovy file next to the template; the contents of the
+// files are recorded among the handler's Scripts. If the template is embedded
+// in the HCL, the scripts are embedded too.
+func (h *Handler) extract(index int, count int, parameters map[string]interface{}) map[string]interface{} {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	base := strings.TrimSuffix(strings.TrimSuffix(h.TemplateFile, ".tpl"), ".xml")
+	if count > 1 {
+		base = fmt.Sprintf("%s.%d", base, index+1)
+	}
+	result := map[string]interface{}{}
+	for _, name := range names {
+		value, ok := parameters[name].(script)
+		switch {
+		case !ok:
+			result[name] = parameters[name]
+		case h.EmbedConfigXML && h.Format == HCL2Format:
+			result[name] = hcl.Heredoc(value)
+		case h.EmbedConfigXML:
+			result[name] = string(value)
+		default:
+			file := base + ".groovy"
+			for i := 2; h.Scripts[file] != ""; i++ {
+				file = fmt.Sprintf("%s.%d.groovy", base, i)
+			}
+			h.Scripts[file] = string(value)
+			result[name] = &hcl.Call{
+				Name:      "file",
+				Arguments: []interface{}{hcl.Template(h.modulePath(file))},
+			}
+		}
+	}
+	return result
+}
+
+// modulePath returns the path of the given file generated alongside the HCL,
+// relative to the Terraform module directory.
+func (h *Handler) modulePath(file string) string {
+	if h.Module != "" {
+		return "${path.module}/templates/" + filepath.Base(file)
+	}
+	return "${path.module}/" + filepath.Base(file)
+}
@@ -0,0 +1,159 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dihedron/jted/hcl"
+)
+
+// secret is the value of a parameter holding a secret: it is used as is when
+// rendering the template, but it is never written to the HCL.
+type secret string
+
+var (
+	// encrypted matches the values encrypted by Jenkins (hudson.util.Secret),
+	// e.g. {AQAAABAAAAAQwt1GRY9q3ZVQO3gt3epgTsk5dMX+jSacfO7NOzm5Eyk=}.
+	encrypted = regexp.MustCompile(`^\{[A-Za-z0-9+/]{20,}={0,2}\}$`)
+	// sensitive matches the names of the tags and attributes that usually hold
+	// secrets in clear (e.g. <password>, <apiToken>).
+	sensitive = regexp.MustCompile(`(?i)(password|passwd|passphrase|secret|token|api-?key|private-?key)`)
+)
+
+// isSecret returns whether the given value of a tag or attribute is a secret,
+// either because it has been encrypted by Jenkins or because of the name of
+// the tag or attribute; references to secrets (e.g. credentialsId) and flags
+// (e.g. <useToken>true</useToken>) are not secrets.
+func isSecret(name string, value string) bool {
+	if value == "" || pattern.MatchString(value) {
+		return false
+	}
+	if encrypted.MatchString(value) {
+		return true
+	}
+	if strings.HasSuffix(name, "Id") || strings.HasSuffix(name, "ID") || value == "true" || value == "false" {
+		return false
+	}
+	return sensitive.MatchString(name)
+}
+
+// hidden returns whether any of the given values of a tag or attribute is a
+// secret: since the actual secret is only known when the template is rendered,
+// and it may contain any character, its references are always XML-escaped.
+func hidden(name string, values []string) bool {
+	for _, value := range values {
+		if isSecret(name, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// secrets returns the given values as secrets if any of them is a secret.
+func secrets(name string, values []string) []interface{} {
+	result := interfaces(values)
+	if hidden(name, values) {
+		for i, value := range values {
+			result[i] = secret(value)
+		}
+	}
+	return result
+}
+
+// withhold returns a copy of the given parameters where each secret value is
+// replaced by a reference to a sensitive variable, whose declaration is added
+// to the given body; the prefix is prepended to the variable names, and the
+// secrets are recorded among the withheld ones.
+func (h *Handler) withhold(variables *hcl.Body, prefix string, parameters map[string]interface{}) map[string]interface{} {
+	taken := map[string]bool{}
+	for _, block := range variables.Blocks {
+		taken[block.Labels[0]] = true
+	}
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var replace func(value interface{}, name string, path string) interface{}
+	replace = func(value interface{}, name string, path string) interface{} {
+		switch v := value.(type) {
+		case secret:
+			variable := prefix + snake(name)
+			for i := 2; taken[variable]; i++ {
+				variable = fmt.Sprintf("%s%s_%d", prefix, snake(name), i)
+			}
+			taken[variable] = true
+			body := hcl.NewBody()
+			body.Set("description", fmt.Sprintf("The secret value of %s in the original config.xml", path))
+			if h.legacy() {
+				// Terraform 0.11 quotes types and has no sensitive variables
+				body.Set("type", "string")
+			} else {
+				body.Set("type", hcl.Reference("string"))
+				body.Set("sensitive", true)
+			}
+			variables.Blocks = append(variables.Blocks, &hcl.Block{
+				Type:   "variable",
+				Labels: []string{variable},
+				Body:   body,
+			})
+			h.Withheld = append(h.Withheld, fmt.Sprintf("%s (variable %s)", path, variable))
+			return hcl.Reference("var." + variable)
+		case []interface{}:
+			result := make([]interface{}, len(v))
+			for i, item := range v {
+				result[i] = replace(item, fmt.Sprintf("%s_%d", name, i+1), fmt.Sprintf("%s[%d]", path, i+1))
+			}
+			return result
+		case map[string]interface{}:
+			// sorted, so that the variable names and the report are stable
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			result := map[string]interface{}{}
+			for _, k := range keys {
+				result[k] = replace(v[k], name+k, path+"/"+k)
+			}
+			return result
+		}
+		return value
+	}
+
+	result := map[string]interface{}{}
+	for _, name := range names {
+		path, ok := h.paths[name]
+		if !ok {
+			path = name
+		}
+		result[name] = replace(parameters[name], name, path)
+	}
+	return result
+}
+
+// computed returns whether the given value contains references (e.g. to
+// sensitive variables) or function calls, which cannot be used in the default
+// values of variables.
+func computed(value interface{}) bool {
+	switch v := value.(type) {
+	case hcl.Reference, *hcl.Call:
+		return true
+	case []interface{}:
+		for _, item := range v {
+			if computed(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if computed(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package generator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/camelcase"
+)
+
+// Node describes a node in the XML tree.
+type Node struct {
+	xml        interface{}           // the XML token (e.g. xml.StartElement)
+	container  bool                  // whether the node contains other nodes
+	value      string                // the (trimmed) text of the node
+	text       string                // the text of the node, as is
+	children   []*Node               // the nodes contained in this node
+	values     []string              // the values of the node in each document of a family
+	attributes [][]string            // the values of each attribute in each document of a family
+	script     bool                  // whether the node holds a Pipeline script, whose text is kept as is
+	cdata      bool                  // whether the text of the node is in a CDATA section
+	positions  []position            // where the element begins, in each document of a family
+	selections map[string]*selection // what the rules select in the element ("") and in its attributes
+}
+
+// rooted returns whether the given document has a root element.
+func rooted(document *Node) bool {
+	for _, child := range document.children {
+		if _, ok := child.xml.(xml.StartElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var pattern *regexp.Regexp
+
+func init() {
+	pattern, _ = regexp.Compile(`^{{[^}}]*}}$`)
+}
+
+// tab creates a string with the given number of tabs; each tab has a size of
+// two blank spaces in the current implementation.
+func tab(count int) string {
+	return fmt.Sprintf(fmt.Sprintf("%%-%ds", count*2), "")
+}
+
+// templatise returns the name of the template parameter for a given tag, e.g.
+// <doSomething> becomes DoSomething and <flow-definition> becomes FlowDefinition
+func templatise(tag string) string {
+	var tokens []string
+	if strings.ContainsAny(tag, ".-") {
+		for _, token := range strings.FieldsFunc(tag, func(r rune) bool { return r == '.' || r == '-' }) {
+			tokens = append(tokens, strings.Title(token))
+		}
+	} else {
+		tokens = camelcase.Split(tag)
+		tokens = append([]string{strings.Title(tokens[0])}, tokens[1:]...)
+	}
+	return strings.Join(tokens, "")
+}
+
+// pluralise returns the name of a list parameter given the name of its items,
+// e.g. BranchSpec becomes BranchSpecs.
+func pluralise(name string) string {
+	if strings.HasSuffix(name, "s") || strings.HasSuffix(name, "x") {
+		return name + "es"
+	}
+	return name + "s"
+}
+
+// constant returns whether all the given values are the same.
+func constant(values []string) bool {
+	for _, value := range values {
+		if value != values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// interfaces converts a slice of strings into a slice of interface{}.
+func interfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
+
+var (
+	// delimiters escapes the template delimiters, which are rendered as they are.
+	delimiters = strings.NewReplacer("{{", `{{"{{"}}`, "}}", `{{"}}"}}`)
+	// entities escapes the characters that cannot appear as they are in XML text
+	// and attribute values.
+	entities = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+	// reference matches the values parameterised "by hand" that refer to a single
+	// parameter by name, e.g. {{- BranchFilterType -}} or {{ .parameters.Spec }};
+	// references to other fields (e.g. {{ .description }}) are not parameters.
+	reference = regexp.MustCompile(`^({{-?\s*)(?:\.parameters\.)?([A-Za-z_][A-Za-z0-9_]*)(\s*-?}})$`)
+)
+
+// escape escapes the given text so it can be safely written in an XML document
+// and in a template, where it is rendered as is.
+func escape(text string) string {
+	return delimiters.Replace(entities.Replace(text))
+}
+
+// literal escapes the template delimiters in the given text, which is written
+// in the template as is (e.g. comments and CDATA sections).
+func literal(text string) string {
+	return delimiters.Replace(text)
+}
+
+// unsafe returns whether any of the given values must be escaped to be written
+// in an XML document.
+func unsafe(values ...string) bool {
+	for _, value := range values {
+		if strings.ContainsAny(value, `&<>"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// snake returns the snake_case form of a parameter name, suitable for use as
+// a Terraform identifier, e.g. TriggerOnPush becomes trigger_on_push.
+func snake(name string) string {
+	var tokens []string
+	for _, token := range camelcase.Split(name) {
+		token = strings.ToLower(strings.TrimFunc(token, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}))
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 || unicode.IsDigit(rune(tokens[0][0])) {
+		tokens = append([]string{"p"}, tokens...)
+	}
+	return strings.Join(tokens, "_")
+}
+
+// split splits a comma-separated list, dropping empty values.
+func split(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// ParseAttributes parses a comma-separated list of the names of the attributes
+// to parameterise ("*" for all).
+func ParseAttributes(value string) []string {
+	return split(value)
+}
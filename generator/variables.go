@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/dihedron/jted/hcl"
+)
+
+// reserved are the names that cannot be used for Terraform input variables.
+var reserved = map[string]bool{
+	"source":     true,
+	"version":    true,
+	"providers":  true,
+	"count":      true,
+	"for_each":   true,
+	"lifecycle":  true,
+	"depends_on": true,
+	"locals":     true,
+}
+
+// secretPlaceholder is the value of the sensitive variables in the generated
+// terraform.tfvars, to be replaced with the actual secrets.
+const secretPlaceholder = "SECRET VALUE HERE"
+
+// writeVariables writes the given variable blocks into the VariablesTF buffer
+// and their values into the TFVars buffer: the default values, or placeholders
+// for the sensitive variables, which have none.
+func (h *Handler) writeVariables(variables *hcl.Body) {
+	if len(variables.Blocks) == 0 {
+		return
+	}
+	values := hcl.NewBody()
+	for _, block := range variables.Blocks {
+		if value, ok := block.Body.Attributes["default"]; ok {
+			values.Set(block.Labels[0], value)
+		} else {
+			// no XML-special characters, so that the template renders as is
+			values.Set(block.Labels[0], secretPlaceholder)
+		}
+	}
+	hcl.Write(&h.VariablesTF, variables)
+	hcl.Write(&h.TFVars, values)
+}
+
+// declareVariables adds a variable block for each parameter to the given body,
+// with the type inferred from the parameter value, the value itself as default
+// and the path of the originating XML element in the description; variables
+// already declared in the body are never redeclared, and the parameters that
+// reference sensitive variables or files are kept as they are, since neither
+// can be used in default values. It returns the parameters map with references to
+// the variables in place of the values.
+func (h *Handler) declareVariables(variables *hcl.Body, parameters []*Parameter) map[string]interface{} {
+	taken := map[string]bool{}
+	for _, block := range variables.Blocks {
+		taken[block.Labels[0]] = true
+	}
+	references := map[string]interface{}{}
+	for _, p := range parameters {
+		if computed(p.Value) {
+			references[p.Name] = p.Value
+			continue
+		}
+		variable := h.Prefix + snake(p.Name)
+		if reserved[variable] {
+			variable += "_value"
+		}
+		for i := 2; taken[variable]; i++ {
+			variable = fmt.Sprintf("%s%s_%d", h.Prefix, snake(p.Name), i)
+		}
+		taken[variable] = true
+		value := h.heredocs(p.Value)
+
+		body := hcl.NewBody()
+		if p.Path != "" {
+			body.Set("description", fmt.Sprintf("The value of %s in the original config.xml", p.Path))
+		} else {
+			body.Set("description", fmt.Sprintf("The value of the %s template parameter", p.Name))
+		}
+		body.Set("type", constraint(value))
+		body.Set("default", value)
+		variables.Blocks = append(variables.Blocks, &hcl.Block{
+			Type:   "variable",
+			Labels: []string{variable},
+			Body:   body,
+		})
+		references[p.Name] = hcl.Reference("var." + variable)
+	}
+	return references
+}
+
+// constraint returns the Terraform type constraint of the given value: bool,
+// number, string, list(...) or object({...}); lists whose items have different
+// types are constrained to any.
+func constraint(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bool:
+		return hcl.Reference("bool")
+	case int64, float64:
+		return hcl.Reference("number")
+	case []interface{}:
+		if len(v) == 0 {
+			return &hcl.Call{Name: "list", Arguments: []interface{}{hcl.Reference("string")}}
+		}
+		item := constraint(v[0])
+		for _, other := range v[1:] {
+			if hcl.Format(constraint(other)) != hcl.Format(item) {
+				return hcl.Reference("any")
+			}
+		}
+		return &hcl.Call{Name: "list", Arguments: []interface{}{item}}
+	case map[string]interface{}:
+		attributes := map[string]interface{}{}
+		for k, item := range v {
+			attributes[k] = constraint(item)
+		}
+		return &hcl.Call{Name: "object", Arguments: []interface{}{attributes}}
+	}
+	return hcl.Reference("string")
+}
@@ -0,0 +1,203 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dihedron/jted/sax"
+	"github.com/dihedron/jted/stack"
+)
+
+// element is a node in the canonical form of an XML document, which ignores
+// whitespaces around text, the order of attributes and the way empty elements
+// are written (<tag/> or <tag></tag>).
+type element struct {
+	name       string            // the tag name, "#comment" or "#directive"
+	attributes map[string]string // the element's attributes
+	text       string            // the trimmed text of the element (or comment)
+	children   []*element        // the child elements, comments and directives
+}
+
+// canonicaliser is a sax.EventHandler that builds the canonical form of an XML
+// document.
+type canonicaliser struct {
+	sax.DefaultHandler
+	stack *stack.Stack
+	root  *element
+}
+
+// OnStartDocument gets ready for parsing a new XML document.
+func (c *canonicaliser) OnStartDocument() error {
+	c.stack = stack.New()
+	c.root = &element{name: "#document"}
+	c.stack.Push(c.root)
+	return nil
+}
+
+// OnStartElement adds a new element to its parent and pushes it onto the stack.
+func (c *canonicaliser) OnStartElement(token xml.StartElement) error {
+	e := &element{name: token.Name.Local, attributes: map[string]string{}}
+	for _, attr := range token.Attr {
+		e.attributes[attr.Name.Local] = attr.Value
+	}
+	parent := c.stack.Top().(*element)
+	parent.children = append(parent.children, e)
+	c.stack.Push(e)
+	return nil
+}
+
+// OnEndElement trims the text of the current element and pops it off the stack.
+func (c *canonicaliser) OnEndElement(token xml.EndElement) error {
+	e := c.stack.Pop().(*element)
+	e.text = strings.TrimSpace(e.text)
+	return nil
+}
+
+// OnCharacterData appends the text to the current element.
+func (c *canonicaliser) OnCharacterData(token xml.CharData) error {
+	c.stack.Top().(*element).text += string(token)
+	return nil
+}
+
+// OnComment adds a comment node to the current element.
+func (c *canonicaliser) OnComment(token xml.Comment) error {
+	parent := c.stack.Top().(*element)
+	parent.children = append(parent.children, &element{name: "#comment", text: strings.TrimSpace(string(token))})
+	return nil
+}
+
+// OnDirective adds a directive node to the current element.
+func (c *canonicaliser) OnDirective(token xml.Directive) error {
+	parent := c.stack.Top().(*element)
+	parent.children = append(parent.children, &element{name: "#directive", text: strings.TrimSpace(string(token))})
+	return nil
+}
+
+// canonicalise parses an XML document into its canonical form.
+func canonicalise(document []byte) (*element, error) {
+	c := &canonicaliser{}
+	parser := &sax.Parser{
+		EventHandler:   c,
+		ErrorHandler:   c,
+		LexicalHandler: c,
+	}
+	if err := parser.Parse(bytes.NewReader(document)); err != nil {
+		return nil, err
+	}
+	if len(c.root.children) == 0 {
+		return nil, fmt.Errorf("no root element found")
+	}
+	c.root.text = strings.TrimSpace(c.root.text)
+	return c.root, nil
+}
+
+// Verify renders the template generated by the handler with the parameters
+// it collected for the document at the given index (always 0, unless several
+// documents of a family were processed together), and compares the result with
+// the original document; it returns the list of differences, each one with the
+// path of the element where it was found.
+func (h *Handler) Verify(index int, original []byte) ([]string, error) {
+	attributes := map[string]interface{}{
+		"parameters": h.parameters[index],
+	}
+	for k, v := range h.resources[index] {
+		attributes[k] = v
+	}
+	job := &Job{
+		Attributes: attributes,
+		Template:   h.ConfigXML.String(),
+	}
+	rendered, err := job.Render()
+	if err != nil {
+		return nil, err
+	}
+	expected, err := canonicalise(original)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing original document: %v", err)
+	}
+	actual, err := canonicalise(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rendered document: %v", err)
+	}
+	return compare("", expected, actual, nil), nil
+}
+
+// compare compares two canonical elements and their children recursively,
+// appending the differences found to the given list.
+func compare(path string, expected *element, actual *element, differences []string) []string {
+	if expected.name != actual.name {
+		return append(differences, fmt.Sprintf("%s: expected <%s>, got <%s>", path, expected.name, actual.name))
+	}
+	if expected.name == "#comment" || expected.name == "#directive" {
+		if expected.text != actual.text {
+			differences = append(differences, fmt.Sprintf("%s: expected %s %q, got %q", path, expected.name[1:], expected.text, actual.text))
+		}
+		return differences
+	}
+
+	names := []string{}
+	for name := range expected.attributes {
+		names = append(names, name)
+	}
+	for name := range actual.attributes {
+		if _, ok := expected.attributes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e, eok := expected.attributes[name]
+		a, aok := actual.attributes[name]
+		switch {
+		case !aok:
+			differences = append(differences, fmt.Sprintf("%s/@%s: attribute missing", path, name))
+		case !eok:
+			differences = append(differences, fmt.Sprintf("%s/@%s: unexpected attribute", path, name))
+		case e != a && !pattern.MatchString(e):
+			differences = append(differences, fmt.Sprintf("%s/@%s: expected %q, got %q", path, name, e, a))
+		}
+	}
+
+	// values that have been parameterised "by hand" cannot be compared
+	if expected.text != actual.text && !pattern.MatchString(expected.text) {
+		differences = append(differences, fmt.Sprintf("%s: expected text %q, got %q", path, expected.text, actual.text))
+	}
+
+	// repeated children are identified by their position among their siblings
+	totals := map[string]int{}
+	for _, children := range [][]*element{expected.children, actual.children} {
+		counts := map[string]int{}
+		for _, child := range children {
+			if counts[child.name]++; counts[child.name] > totals[child.name] {
+				totals[child.name] = counts[child.name]
+			}
+		}
+	}
+	counts := map[string]int{}
+	for i := 0; i < len(expected.children) || i < len(actual.children); i++ {
+		var child string
+		if i < len(expected.children) {
+			child = expected.children[i].name
+		} else {
+			child = actual.children[i].name
+		}
+		counts[child]++
+		if totals[child] > 1 {
+			child = fmt.Sprintf("%s/%s[%d]", path, child, counts[child])
+		} else {
+			child = fmt.Sprintf("%s/%s", path, child)
+		}
+		switch {
+		case i >= len(actual.children):
+			differences = append(differences, fmt.Sprintf("%s: missing", child))
+		case i >= len(expected.children):
+			differences = append(differences, fmt.Sprintf("%s: unexpected", child))
+		default:
+			differences = compare(child, expected.children[i], actual.children[i], differences)
+		}
+	}
+	return differences
+}
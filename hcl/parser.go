@@ -0,0 +1,504 @@
+// Package hcl provides a minimal reader and writer for the subset of the
+// HashiCorp Configuration Language that is needed to write and read back the
+// Terraform files generated by jted: blocks, attributes, strings, heredocs,
+// numbers, booleans, lists, maps, references and function calls; operators and
+// conditional expressions are not supported. The reader accepts both the
+// legacy and the 0.12+ syntax, the writer only produces the latter.
+package hcl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Body is the content of an HCL file or block.
+type Body struct {
+	Attributes map[string]interface{}
+	Blocks     []*Block
+	order      []string
+}
+
+// Block is an HCL block, e.g. resource "jenkins_job" "example" { ... }.
+type Block struct {
+	Comment string
+	Type    string
+	Labels  []string
+	Body    *Body
+}
+
+// NewBody creates a new, empty Body.
+func NewBody() *Body {
+	return &Body{Attributes: map[string]interface{}{}}
+}
+
+// Set sets the value of an attribute; attributes are written out in the order
+// in which they were first set.
+func (b *Body) Set(name string, value interface{}) {
+	if _, ok := b.Attributes[name]; !ok {
+		b.order = append(b.order, name)
+	}
+	b.Attributes[name] = value
+}
+
+// Names returns the names of the attributes, in the order in which they were
+// first set.
+func (b *Body) Names() []string {
+	names := make([]string, 0, len(b.Attributes))
+	for _, name := range b.order {
+		if _, ok := b.Attributes[name]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) < len(b.Attributes) {
+		// attributes added directly to the map go last, in alphabetical order
+		var others []string
+		for name := range b.Attributes {
+			if !contains(b.order, name) {
+				others = append(others, name)
+			}
+		}
+		sort.Strings(others)
+		names = append(names, others...)
+	}
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Reference is a reference to a named value, e.g. var.name or path.module.
+type Reference string
+
+// Call is a function call, e.g. file("config.xml.tpl").
+type Call struct {
+	Name      string
+	Arguments []interface{}
+}
+
+// Attribute returns the value of the given attribute, or nil if the body has
+// no such attribute.
+func (b *Body) Attribute(name string) interface{} {
+	return b.Attributes[name]
+}
+
+// Block returns the first block with the given type and labels (if any).
+func (b *Body) Block(kind string, labels ...string) *Block {
+outer:
+	for _, block := range b.Blocks {
+		if block.Type != kind || len(block.Labels) < len(labels) {
+			continue
+		}
+		for i, label := range labels {
+			if block.Labels[i] != label {
+				continue outer
+			}
+		}
+		return block
+	}
+	return nil
+}
+
+// Parse reads an HCL document; values are returned as Go values: strings,
+// int64 and float64 numbers, booleans, nil, []interface{} for lists and tuples,
+// map[string]interface{} for maps and objects, Reference and *Call. Strings are
+// unquoted, but template sequences (${...} and %{...}) are left untouched.
+func Parse(reader io.Reader) (*Body, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{input: []rune(string(data)), line: 1}
+	body, err := p.body(true)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+type parser struct {
+	input []rune
+	pos   int
+	line  int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek() rune {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) next() rune {
+	r := p.peek()
+	if r != 0 {
+		p.pos++
+		if r == '\n' {
+			p.line++
+		}
+	}
+	return r
+}
+
+func (p *parser) hasPrefix(prefix string) bool {
+	end := p.pos + len([]rune(prefix))
+	return end <= len(p.input) && string(p.input[p.pos:end]) == prefix
+}
+
+// skip skips whitespaces, newlines, commas (when allowed) and comments.
+func (p *parser) skip(commas bool) error {
+	for p.pos < len(p.input) {
+		switch {
+		case unicode.IsSpace(p.peek()) || (commas && p.peek() == ','):
+			p.next()
+		case p.peek() == '#' || p.hasPrefix("//"):
+			for p.pos < len(p.input) && p.peek() != '\n' {
+				p.next()
+			}
+		case p.hasPrefix("/*"):
+			for !p.hasPrefix("*/") {
+				if p.next() == 0 {
+					return p.errorf("unterminated comment")
+				}
+			}
+			p.next()
+			p.next()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// body parses attributes and blocks up to the closing brace (or the end of
+// input, for the top level body).
+func (p *parser) body(top bool) (*Body, error) {
+	body := NewBody()
+	for {
+		if err := p.skip(false); err != nil {
+			return nil, err
+		}
+		switch r := p.peek(); {
+		case r == 0:
+			if !top {
+				return nil, p.errorf("unexpected end of input, expecting '}'")
+			}
+			return body, nil
+		case r == '}':
+			if top {
+				return nil, p.errorf("unexpected '}'")
+			}
+			p.next()
+			return body, nil
+		}
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skip(false); err != nil {
+			return nil, err
+		}
+		if p.peek() == '=' {
+			p.next()
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			body.Set(key, value)
+			continue
+		}
+		block := &Block{Type: key}
+		for p.peek() != '{' {
+			label, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			block.Labels = append(block.Labels, label)
+			if err := p.skip(false); err != nil {
+				return nil, err
+			}
+		}
+		p.next()
+		if block.Body, err = p.body(false); err != nil {
+			return nil, err
+		}
+		body.Blocks = append(body.Blocks, block)
+	}
+}
+
+// key parses an identifier or a quoted string.
+func (p *parser) key() (string, error) {
+	if p.peek() == '"' {
+		return p.quoted()
+	}
+	if identifier := p.identifier(); identifier != "" {
+		return identifier, nil
+	}
+	return "", p.errorf("unexpected character %q, expecting identifier", p.peek())
+}
+
+func (p *parser) identifier() string {
+	start := p.pos
+	for r := p.peek(); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'; r = p.peek() {
+		p.next()
+	}
+	return string(p.input[start:p.pos])
+}
+
+// value parses an expression.
+func (p *parser) value() (interface{}, error) {
+	if err := p.skip(false); err != nil {
+		return nil, err
+	}
+	switch r := p.peek(); {
+	case r == '"':
+		return p.quoted()
+	case p.hasPrefix("<<"):
+		return p.heredoc()
+	case r == '[':
+		return p.list()
+	case r == '{':
+		return p.object()
+	case r == '-' || unicode.IsDigit(r):
+		return p.number()
+	case unicode.IsLetter(r) || r == '_':
+		identifier := p.identifier()
+		switch identifier {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if p.peek() == '(' {
+			return p.call(identifier)
+		}
+		for p.peek() == '.' {
+			p.next()
+			identifier += "." + p.identifier()
+		}
+		return Reference(identifier), nil
+	}
+	return nil, p.errorf("unexpected character %q, expecting value", p.peek())
+}
+
+// quoted parses a quoted string, processing escape sequences.
+func (p *parser) quoted() (string, error) {
+	p.next()
+	var buffer strings.Builder
+	for {
+		r := p.next()
+		switch r {
+		case 0, '\n':
+			return "", p.errorf("unterminated string")
+		case '"':
+			return buffer.String(), nil
+		case '\\':
+			switch e := p.next(); e {
+			case 'n':
+				buffer.WriteRune('\n')
+			case 't':
+				buffer.WriteRune('\t')
+			case 'r':
+				buffer.WriteRune('\r')
+			case '"', '\\':
+				buffer.WriteRune(e)
+			case 'u', 'U':
+				size := 4
+				if e == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.input) {
+					return "", p.errorf("invalid unicode escape sequence")
+				}
+				code, err := strconv.ParseUint(string(p.input[p.pos:p.pos+size]), 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape sequence")
+				}
+				p.pos += size
+				buffer.WriteRune(rune(code))
+			default:
+				return "", p.errorf("invalid escape sequence \\%c", e)
+			}
+		case '$', '%':
+			buffer.WriteRune(r)
+			if p.peek() == '{' && !strings.HasSuffix(buffer.String(), string([]rune{r, r})) {
+				// template sequences may contain quotes, copy them as they are
+				depth := 0
+				for {
+					c := p.next()
+					if c == 0 {
+						return "", p.errorf("unterminated template sequence")
+					}
+					buffer.WriteRune(c)
+					if c == '{' {
+						depth++
+					} else if c == '}' {
+						if depth--; depth == 0 {
+							break
+						}
+					}
+				}
+			}
+		default:
+			buffer.WriteRune(r)
+		}
+	}
+}
+
+// heredoc parses a <<EOF or an indented <<-EOF heredoc string.
+func (p *parser) heredoc() (string, error) {
+	p.pos += 2
+	indented := false
+	if p.peek() == '-' {
+		p.next()
+		indented = true
+	}
+	marker := p.identifier()
+	if marker == "" {
+		return "", p.errorf("invalid heredoc marker")
+	}
+	for p.peek() != '\n' {
+		if p.next() == 0 {
+			return "", p.errorf("unterminated heredoc")
+		}
+	}
+	p.next()
+	var lines []string
+	for {
+		start := p.pos
+		for p.peek() != '\n' && p.peek() != 0 {
+			p.next()
+		}
+		line := string(p.input[start:p.pos])
+		if strings.TrimSpace(line) == marker {
+			break
+		}
+		if p.next() == 0 {
+			return "", p.errorf("unterminated heredoc, expecting %s", marker)
+		}
+		lines = append(lines, line)
+	}
+	if indented {
+		// remove the leading whitespaces common to all non-blank lines
+		indent := -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if n := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace)); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+		for i, line := range lines {
+			if len(line) >= indent && indent > 0 {
+				lines[i] = line[indent:]
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// list parses a list (or tuple) of values.
+func (p *parser) list() ([]interface{}, error) {
+	p.next()
+	values := []interface{}{}
+	for {
+		if err := p.skip(true); err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.next()
+			return values, nil
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+// object parses a map (or object) of values.
+func (p *parser) object() (map[string]interface{}, error) {
+	p.next()
+	values := map[string]interface{}{}
+	for {
+		if err := p.skip(true); err != nil {
+			return nil, err
+		}
+		if p.peek() == '}' {
+			p.next()
+			return values, nil
+		}
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skip(false); err != nil {
+			return nil, err
+		}
+		if r := p.next(); r != '=' && r != ':' {
+			return nil, p.errorf("unexpected character %q, expecting '=' or ':'", r)
+		}
+		if values[key], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// number parses an integer or a floating point number.
+func (p *parser) number() (interface{}, error) {
+	start := p.pos
+	p.next()
+	for r := p.peek(); unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E' || r == '+' || r == '-'; r = p.peek() {
+		p.next()
+	}
+	text := string(p.input[start:p.pos])
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", text)
+	}
+	return f, nil
+}
+
+// call parses the arguments of a function call.
+func (p *parser) call(name string) (*Call, error) {
+	p.next()
+	call := &Call{Name: name}
+	for {
+		if err := p.skip(true); err != nil {
+			return nil, err
+		}
+		if p.peek() == ')' {
+			p.next()
+			return call, nil
+		}
+		argument, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		call.Arguments = append(call.Arguments, argument)
+	}
+}
@@ -0,0 +1,63 @@
+package hcl
+
+import (
+	"strings"
+	"testing"
+)
+
+const document = `
+/*
+ * Jenkins job definition
+ */
+resource "jenkins_job" "example" {
+	name                                = "example"
+	disabled                            = false # a comment
+	parameters                          = {
+		ConfigVersion                       = 2,
+		"{{CISkip}}"                        = "<no value provided>",
+		Strings                             = [
+			"fast",
+			"slow",
+		],
+	}
+	template                            = <<EOF
+<project>
+  <description>{{- .description -}}</description>
+</project>
+EOF
+	script                              = file("${path.module}/Jenkinsfile")
+	owner                               = var.owner
+}
+`
+
+func TestParse(t *testing.T) {
+	body, err := Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("error parsing document: %v", err)
+	}
+	block := body.Block("resource", "jenkins_job")
+	if block == nil || block.Labels[1] != "example" {
+		t.Fatalf("resource block not found")
+	}
+	attributes := block.Body.Attributes
+	if attributes["name"] != "example" || attributes["disabled"] != false {
+		t.Errorf("invalid attributes: %v", attributes)
+	}
+	parameters := attributes["parameters"].(map[string]interface{})
+	if parameters["ConfigVersion"] != int64(2) || parameters["{{CISkip}}"] != "<no value provided>" {
+		t.Errorf("invalid parameters: %v", parameters)
+	}
+	if list := parameters["Strings"].([]interface{}); len(list) != 2 || list[1] != "slow" {
+		t.Errorf("invalid list: %v", parameters["Strings"])
+	}
+	if attributes["template"] != "<project>\n  <description>{{- .description -}}</description>\n</project>\n" {
+		t.Errorf("invalid heredoc: %q", attributes["template"])
+	}
+	call, ok := attributes["script"].(*Call)
+	if !ok || call.Name != "file" || call.Arguments[0] != "${path.module}/Jenkinsfile" {
+		t.Errorf("invalid function call: %#v", attributes["script"])
+	}
+	if attributes["owner"] != Reference("var.owner") {
+		t.Errorf("invalid reference: %#v", attributes["owner"])
+	}
+}
@@ -0,0 +1,226 @@
+package hcl
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Heredoc is a string that is written in heredoc form (<<EOT ... EOT).
+type Heredoc string
+
+// Template is a string that is written as a quoted template, without escaping
+// its template sequences, e.g. "${path.module}/config.xml.tpl".
+type Template string
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Write writes the body in HCL (Terraform 0.12+) syntax, formatted the same
+// way as terraform fmt would: two spaces indentation, with the equal signs of
+// consecutive attributes aligned. Attribute values can be strings, numbers,
+// booleans, nil, lists ([]interface{}), maps (map[string]interface{}), with
+// keys in alphabetical order, Reference, *Call, Heredoc and Template values;
+// strings are escaped, so that "${" and "%{" sequences are never evaluated.
+func Write(w io.Writer, body *Body) error {
+	var buffer strings.Builder
+	writeBody(&buffer, body, 0)
+	_, err := io.WriteString(w, buffer.String())
+	return err
+}
+
+// Format returns the HCL representation of a single value.
+func Format(value interface{}) string {
+	var buffer strings.Builder
+	writeValue(&buffer, value, 0)
+	return buffer.String()
+}
+
+// Key returns the given name as an object key, quoted if it is not a valid
+// identifier.
+func Key(name string) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+	return Quote(name)
+}
+
+// Quote returns the given string as a quoted HCL string, escaping template
+// sequences.
+func Quote(value string) string {
+	value = quote(value)
+	value = strings.Replace(value, "${", "$${", -1)
+	value = strings.Replace(value, "%{", "%%{", -1)
+	return value
+}
+
+// quote returns the given string as a quoted HCL string, with the only escape
+// sequences HCL supports: \\, \", \n, \r, \t and \uNNNN (or \UNNNNNNNN) for the
+// other control and non-printable characters.
+func quote(value string) string {
+	var buffer strings.Builder
+	buffer.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '\\':
+			buffer.WriteString(`\\`)
+		case r == '"':
+			buffer.WriteString(`\"`)
+		case r == '\n':
+			buffer.WriteString(`\n`)
+		case r == '\r':
+			buffer.WriteString(`\r`)
+		case r == '\t':
+			buffer.WriteString(`\t`)
+		case unicode.IsPrint(r):
+			buffer.WriteRune(r)
+		case r > 0xFFFF:
+			fmt.Fprintf(&buffer, `\U%08X`, r)
+		default:
+			fmt.Fprintf(&buffer, `\u%04X`, r)
+		}
+	}
+	buffer.WriteByte('"')
+	return buffer.String()
+}
+
+func writeBody(buffer *strings.Builder, body *Body, depth int) {
+	indent := strings.Repeat("  ", depth)
+	names := body.Names()
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = Key(name)
+	}
+	writeAttributes(buffer, keys, func(i int) interface{} { return body.Attributes[names[i]] }, depth)
+	for i, block := range body.Blocks {
+		if i > 0 || len(names) > 0 {
+			buffer.WriteString("\n")
+		}
+		if block.Comment != "" {
+			buffer.WriteString(indent + "/*\n")
+			for _, line := range strings.Split(block.Comment, "\n") {
+				buffer.WriteString(strings.TrimRight(indent+" * "+line, " ") + "\n")
+			}
+			buffer.WriteString(indent + " */\n")
+		}
+		buffer.WriteString(indent + block.Type)
+		for _, label := range block.Labels {
+			buffer.WriteString(" " + quote(label))
+		}
+		buffer.WriteString(" {\n")
+		writeBody(buffer, block.Body, depth+1)
+		buffer.WriteString(indent + "}\n")
+	}
+}
+
+// writeAttributes writes "key = value" lines, aligning the equal signs of runs
+// of consecutive attributes; a multi-line value ends the run.
+func writeAttributes(buffer *strings.Builder, keys []string, value func(i int) interface{}, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for start := 0; start < len(keys); {
+		end, width := start, 0
+		for end < len(keys) {
+			if len(keys[end]) > width {
+				width = len(keys[end])
+			}
+			end++
+			if multiline(value(end - 1)) {
+				break
+			}
+		}
+		for i := start; i < end; i++ {
+			buffer.WriteString(fmt.Sprintf("%s%-*s = ", indent, width, keys[i]))
+			writeValue(buffer, value(i), depth)
+			buffer.WriteString("\n")
+		}
+		start = end
+	}
+}
+
+func multiline(value interface{}) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	case Heredoc:
+		return true
+	}
+	return false
+}
+
+func writeValue(buffer *strings.Builder, value interface{}, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case string:
+		buffer.WriteString(Quote(v))
+	case Template:
+		buffer.WriteString(quote(string(v)))
+	case Heredoc:
+		marker := "EOT"
+		for strings.Contains("\n"+string(v)+"\n", "\n"+marker+"\n") {
+			marker += "_"
+		}
+		text := strings.Replace(string(v), "${", "$${", -1)
+		text = strings.Replace(text, "%{", "%%{", -1)
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		buffer.WriteString("<<" + marker + "\n" + text + marker)
+	case bool:
+		buffer.WriteString(strconv.FormatBool(v))
+	case int:
+		buffer.WriteString(strconv.Itoa(v))
+	case int64:
+		buffer.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		buffer.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case Reference:
+		buffer.WriteString(string(v))
+	case *Call:
+		buffer.WriteString(v.Name + "(")
+		for i, argument := range v.Arguments {
+			if i > 0 {
+				buffer.WriteString(", ")
+			}
+			writeValue(buffer, argument, depth)
+		}
+		buffer.WriteString(")")
+	case []interface{}:
+		if len(v) == 0 {
+			buffer.WriteString("[]")
+			return
+		}
+		buffer.WriteString("[\n")
+		for _, item := range v {
+			buffer.WriteString(indent + "  ")
+			writeValue(buffer, item, depth+1)
+			buffer.WriteString(",\n")
+		}
+		buffer.WriteString(indent + "]")
+	case map[string]interface{}:
+		if len(v) == 0 {
+			buffer.WriteString("{}")
+			return
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		keys := make([]string, len(names))
+		for i, name := range names {
+			keys[i] = Key(name)
+		}
+		buffer.WriteString("{\n")
+		writeAttributes(buffer, keys, func(i int) interface{} { return v[names[i]] }, depth+1)
+		buffer.WriteString(indent + "}")
+	default:
+		buffer.WriteString(Quote(fmt.Sprintf("%v", v)))
+	}
+}
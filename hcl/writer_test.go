@@ -0,0 +1,81 @@
+package hcl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	body := NewBody()
+	body.Set("name", "example")
+	body.Set("disabled", false)
+	body.Set("parameters", map[string]interface{}{
+		"Script":     "echo ${HOME} %{if} \"quoted\"",
+		"{{CISkip}}": int64(2),
+		"Strings":    []interface{}{"fast", "slow"},
+	})
+	body.Set("template", &Call{Name: "file", Arguments: []interface{}{Template("${path.module}/config.xml.tpl")}})
+	resource := NewBody()
+	resource.Blocks = append(resource.Blocks, &Block{
+		Comment: "Jenkins job definition",
+		Type:    "resource",
+		Labels:  []string{"jenkins_job", "example"},
+		Body:    body,
+	})
+
+	var buffer strings.Builder
+	if err := Write(&buffer, resource); err != nil {
+		t.Fatalf("error writing document: %v", err)
+	}
+	expected := `/*
+ * Jenkins job definition
+ */
+resource "jenkins_job" "example" {
+  name       = "example"
+  disabled   = false
+  parameters = {
+    Script  = "echo $${HOME} %%{if} \"quoted\""
+    Strings = [
+      "fast",
+      "slow",
+    ]
+    "{{CISkip}}" = 2
+  }
+  template = file("${path.module}/config.xml.tpl")
+}
+`
+	if buffer.String() != expected {
+		t.Errorf("invalid document: expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+
+	parsed, err := Parse(strings.NewReader(buffer.String()))
+	if err != nil {
+		t.Fatalf("error parsing document: %v", err)
+	}
+	parameters := parsed.Block("resource", "jenkins_job", "example").Body.Attribute("parameters").(map[string]interface{})
+	if parameters["Script"] != "echo $${HOME} %%{if} \"quoted\"" {
+		t.Errorf("invalid round trip: %q", parameters["Script"])
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for value, expected := range map[string]string{
+		"a\\b \"c\"":      `"a\\b \"c\""`,
+		"1\n2\r\n3\t4":    `"1\n2\r\n3\t4"`,
+		"\x01\a\v\x7f":    `"\u0001\u0007\u000B\u007F"`,
+		"café \U0001F600": `"café 😀"`,
+		"tag\U000E0001":   `"tag\U000E0001"`,
+		"${HOME}":         `"$${HOME}"`,
+	} {
+		quoted := Quote(value)
+		if quoted != expected {
+			t.Errorf("invalid quoted string: expected %s, got %s", expected, quoted)
+		}
+		parsed, err := Parse(strings.NewReader("value = " + quoted + "\n"))
+		if err != nil {
+			t.Errorf("error parsing %s: %v", quoted, err)
+		} else if parsed.Attribute("value") != strings.Replace(value, "${", "$${", -1) {
+			t.Errorf("invalid round trip of %s: %q", quoted, parsed.Attribute("value"))
+		}
+	}
+}
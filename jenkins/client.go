@@ -0,0 +1,203 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// Client is a client of the Jenkins REST API, authenticating with a user name
+// and an API token (or password); the CSRF protection crumb, if the server
+// issues one, is requested once and sent along with all modifying requests.
+type Client struct {
+	URL   string       // the base URL of the server, e.g. https://jenkins.example.com/
+	User  string       // the user name, if any
+	Token string       // the API token (or password) of the user
+	HTTP  *http.Client // the underlying HTTP client
+	crumb *crumb       // the CSRF protection crumb, once requested
+}
+
+// Job is a job on a Jenkins server.
+type Job struct {
+	Name   string // the full name of the job, e.g. folder/job
+	Class  string // the Java class of the job, e.g. hudson.model.FreeStyleProject
+	Folder bool   // whether the job contains other jobs
+}
+
+// Error is the error returned when the server does not accept a request.
+type Error struct {
+	Method     string // the method of the request
+	URL        string // the URL of the request
+	StatusCode int    // the HTTP status code of the response
+	Status     string // the HTTP status of the response
+	Message    string // the body of the response, with the details of the error
+}
+
+// Error returns a description of the error.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.URL, e.Status, e.Message)
+}
+
+// crumb is a CSRF protection crumb, along with the header it goes into.
+type crumb struct {
+	Field string `json:"crumbRequestField"`
+	Value string `json:"crumb"`
+}
+
+// New creates a new Client for the Jenkins server at the given URL; the HTTP
+// client keeps the session cookies, which crumbs may be bound to.
+func New(base string, user string, token string) *Client {
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		URL:   strings.TrimSuffix(base, "/"),
+		User:  user,
+		Token: token,
+		HTTP:  &http.Client{Jar: jar},
+	}
+}
+
+// Path returns the path of the job with the given full name, relative to the
+// base URL of the server, e.g. /job/folder/job/name for folder/name.
+func Path(name string) string {
+	var path string
+	for _, segment := range strings.Split(strings.Trim(name, "/"), "/") {
+		if segment != "" {
+			path += "/job/" + url.PathEscape(segment)
+		}
+	}
+	return path
+}
+
+// Jobs returns the jobs in the folder with the given full name (or at the top
+// level, if empty) and, recursively, in its sub-folders; the folders are among
+// the jobs, but the contents of multibranch projects and organisation folders,
+// which are computed by Jenkins, are not.
+func (c *Client) Jobs(folder string) ([]*Job, error) {
+	response, err := c.Do("GET", Path(folder)+"/api/json?tree=jobs[name,_class,jobs[name]]", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var listing struct {
+		Jobs []struct {
+			Name  string        `json:"name"`
+			Class string        `json:"_class"`
+			Jobs  []interface{} `json:"jobs"`
+		} `json:"jobs"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("error decoding jobs of %q: %v", folder, err)
+	}
+	var jobs []*Job
+	for _, item := range listing.Jobs {
+		job := &Job{
+			Name:   strings.TrimPrefix(folder+"/"+item.Name, "/"),
+			Class:  item.Class,
+			Folder: item.Jobs != nil,
+		}
+		jobs = append(jobs, job)
+		if job.Folder && !strings.HasSuffix(job.Class, "MultiBranchProject") && !strings.HasSuffix(job.Class, "OrganizationFolder") {
+			children, err := c.Jobs(job.Name)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, children...)
+		}
+	}
+	return jobs, nil
+}
+
+// ConfigXML returns the config.xml of the job with the given full name; the
+// caller must close it.
+func (c *Client) ConfigXML(name string) (io.ReadCloser, error) {
+	response, err := c.Do("GET", Path(name)+"/config.xml", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// Do sends a request to the server, with the given path relative to its base
+// URL, and returns the response, or an Error if the status code is not 2xx;
+// the caller must close the body of the response. Requests other than GET
+// carry the CSRF protection crumb, if the server issues one.
+func (c *Client) Do(method string, path string, body io.Reader, contentType string) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if method != "GET" {
+		crumb, err := c.issue()
+		if err != nil {
+			return nil, err
+		}
+		if crumb.Field != "" {
+			request.Header.Set(crumb.Field, crumb.Value)
+		}
+	}
+	return c.send(request)
+}
+
+// send sends the given request, with the credentials, and checks the status
+// code of the response.
+func (c *Client) send(request *http.Request) (*http.Response, error) {
+	if c.User != "" {
+		request.SetBasicAuth(c.User, c.Token)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 64*1024))
+		return nil, &Error{
+			Method:     request.Method,
+			URL:        request.URL.String(),
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+	return response, nil
+}
+
+// issue returns the CSRF protection crumb, requesting it to the server the
+// first time; servers with CSRF protection disabled do not issue crumbs.
+func (c *Client) issue() (*crumb, error) {
+	if c.crumb != nil {
+		return c.crumb, nil
+	}
+	request, err := http.NewRequest("GET", c.URL+"/crumbIssuer/api/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.send(request)
+	if e, ok := err.(*Error); ok && e.StatusCode == http.StatusNotFound {
+		c.crumb = &crumb{}
+		return c.crumb, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	c.crumb = &crumb{}
+	if err := json.NewDecoder(response.Body).Decode(c.crumb); err != nil {
+		return nil, fmt.Errorf("error decoding crumb: %v", err)
+	}
+	return c.crumb, nil
+}
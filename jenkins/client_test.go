@@ -0,0 +1,159 @@
+package jenkins
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// server returns a stand-in for a Jenkins server with a job at the top level,
+// a folder with a job and a multibranch project, which requires authentication
+// and crumbs.
+func server(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, token, ok := r.BasicAuth(); !ok || user != "admin" || token != "secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != "GET" && r.Header.Get("Jenkins-Crumb") != "1234" {
+			http.Error(w, "No valid crumb was included in the request", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/crumbIssuer/api/json":
+			fmt.Fprint(w, `{"_class":"hudson.security.csrf.DefaultCrumbIssuer","crumb":"1234","crumbRequestField":"Jenkins-Crumb"}`)
+		case "/api/json":
+			fmt.Fprint(w, `{"jobs":[
+				{"_class":"hudson.model.FreeStyleProject","name":"a"},
+				{"_class":"com.cloudbees.hudson.plugins.folder.Folder","name":"team","jobs":[{"name":"my app"}]},
+				{"_class":"org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject","name":"mb","jobs":[{"name":"master"}]}
+			]}`)
+		case "/job/team/api/json":
+			fmt.Fprint(w, `{"jobs":[{"_class":"org.jenkinsci.plugins.workflow.job.WorkflowJob","name":"my app"}]}`)
+		case "/job/team/job/my app/config.xml":
+			fmt.Fprint(w, `<flow-definition><description>My app</description></flow-definition>`)
+		case "/job/team/job/my app/doDelete":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestJobs(t *testing.T) {
+	s := server(t)
+	defer s.Close()
+
+	jobs, err := New(s.URL+"/", "admin", "secret").Jobs("")
+	if err != nil {
+		t.Fatalf("error listing jobs: %v", err)
+	}
+	var names []string
+	for _, job := range jobs {
+		names = append(names, fmt.Sprintf("%s:%t", job.Name, job.Folder))
+	}
+	if strings.Join(names, ",") != "a:false,team:true,team/my app:false,mb:true" {
+		t.Errorf("invalid jobs: %v", names)
+	}
+
+	if _, err := New(s.URL, "admin", "wrong").Jobs(""); err == nil || err.(*Error).StatusCode != http.StatusUnauthorized {
+		t.Errorf("invalid error: %v", err)
+	}
+}
+
+func TestConfigXML(t *testing.T) {
+	s := server(t)
+	defer s.Close()
+
+	client := New(s.URL, "admin", "secret")
+	body, err := client.ConfigXML("team/my app")
+	if err != nil {
+		t.Fatalf("error fetching config.xml: %v", err)
+	}
+	defer body.Close()
+	data, _ := ioutil.ReadAll(body)
+	if string(data) != `<flow-definition><description>My app</description></flow-definition>` {
+		t.Errorf("invalid config.xml: %s", data)
+	}
+
+	if _, err := client.ConfigXML("missing"); err == nil || err.(*Error).StatusCode != http.StatusNotFound {
+		t.Errorf("invalid error: %v", err)
+	}
+
+	response, err := client.Do("POST", Path("team/my app")+"/doDelete", nil, "")
+	if err != nil {
+		t.Fatalf("error posting with crumb: %v", err)
+	}
+	response.Body.Close()
+}
+
+func TestPush(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/job/team/job/my-app/config.xml":
+			fmt.Fprint(w, `<project/>`)
+		case r.Method == "POST" && r.URL.Path == "/job/team/job/my-app/config.xml":
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "<bogus>") {
+				http.Error(w, "<html><body><pre>java.io.IOException: Unable to read\n"+
+					"---- Debugging information ----\n"+
+					"path : /project/bogus\n"+
+					"line number : 3\n</pre></body></html>", http.StatusInternalServerError)
+				return
+			}
+			posted = append(posted, "update "+r.URL.Path)
+		case r.Method == "POST" && r.URL.Path == "/job/team/createItem":
+			if r.Header.Get("Content-Type") != "application/xml" {
+				http.Error(w, "invalid content type", http.StatusBadRequest)
+				return
+			}
+			posted = append(posted, "create "+r.URL.Query().Get("name"))
+		case r.Method == "POST" && r.URL.Path == "/createItem":
+			http.Error(w, "org.xml.sax.SAXParseException; lineNumber: 3; columnNumber: 5; invalid", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := New(server.URL, "", "")
+
+	if created, err := client.Push("team/my-app", []byte(`<project/>`)); err != nil || created {
+		t.Errorf("invalid update: %t, %v", created, err)
+	}
+	if created, err := client.Push("team/new app", []byte(`<project/>`)); err != nil || !created {
+		t.Errorf("invalid creation: %t, %v", created, err)
+	}
+	if strings.Join(posted, ",") != "update /job/team/job/my-app/config.xml,create new app" {
+		t.Errorf("invalid requests: %v", posted)
+	}
+
+	_, err := client.Push("team/my-app", []byte("<project>\n  <description/>\n  <bogus>x</bogus>\n</project>"))
+	if e, ok := err.(*PushError); !ok || e.Element != "/project/bogus" || !strings.Contains(e.Error(), "java.io.IOException: Unable to read") {
+		t.Errorf("invalid error: %v", err)
+	}
+	_, err = client.Push("other", []byte("<project>\n  <description/>\n  <builders><x/></builders>\n</project>"))
+	if e, ok := err.(*PushError); !ok || e.Element != "/project/builders, line 3" {
+		t.Errorf("invalid error: %v", err)
+	}
+
+	if err := WellFormed([]byte("<project>\n  <description>\n</project>")); err == nil || !strings.Contains(err.Error(), "/project/description") {
+		t.Errorf("invalid error: %v", err)
+	}
+
+	// Jenkins writes its config.xml files in XML 1.1
+	document := []byte("<?xml version='1.1' encoding='UTF-8'?>\n<project>\n  <description/>\n  <bogus>x</bogus>\n</project>")
+	if err := WellFormed(document); err != nil {
+		t.Errorf("invalid error on XML 1.1 document: %v", err)
+	}
+	if err := WellFormed([]byte("<?xml version='1.1'?>\n<project>\n  <description>\n</project>")); err == nil || !strings.Contains(err.Error(), "/project/description") {
+		t.Errorf("invalid error: %v", err)
+	}
+	_, err = client.Push("other", document)
+	if e, ok := err.(*PushError); !ok || e.Element != "/project/description, line 3" {
+		t.Errorf("invalid error: %v", err)
+	}
+}
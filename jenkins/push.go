@@ -0,0 +1,170 @@
+package jenkins
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Push sends the given config.xml to the server, updating the job with the
+// given full name if it exists, or creating it otherwise; it returns whether
+// the job was created. If the server rejects the config.xml, the error is a
+// PushError reporting the offending element, if it can be found.
+func (c *Client) Push(name string, config []byte) (bool, error) {
+	path := Path(name)
+	created := false
+	response, err := c.Do("GET", path+"/config.xml", nil, "")
+	if e, ok := err.(*Error); ok && e.StatusCode == http.StatusNotFound {
+		// the job does not exist, it must be created in its folder
+		created = true
+		folder, leaf := "", name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			folder, leaf = name[:i], name[i+1:]
+		}
+		path = Path(folder) + "/createItem?name=" + url.QueryEscape(leaf)
+	} else if err != nil {
+		return false, err
+	} else {
+		response.Body.Close()
+		path += "/config.xml"
+	}
+	response, err = c.Do("POST", path, bytes.NewReader(config), "application/xml")
+	if e, ok := err.(*Error); ok {
+		return false, &PushError{Cause: e, Element: offending(e.Message, config)}
+	} else if err != nil {
+		return false, err
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	return created, nil
+}
+
+// PushError is the error returned when the server rejects a config.xml.
+type PushError struct {
+	Cause   *Error // the error returned by the server
+	Element string // the path of the offending element, if known
+}
+
+// Error returns a description of the error, with the first line of the
+// server's explanation and the offending element.
+func (e *PushError) Error() string {
+	message := fmt.Sprintf("%s %s: %s", e.Cause.Method, e.Cause.URL, e.Cause.Status)
+	if cause := explanation(e.Cause.Message); cause != "" {
+		message += ": " + cause
+	}
+	if e.Element != "" {
+		message += " (offending element: " + e.Element + ")"
+	}
+	return message
+}
+
+// WellFormed checks that the given config.xml is well-formed XML, reporting
+// the offending element otherwise.
+func WellFormed(config []byte) error {
+	d := decoder(config)
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			if e, ok := err.(*xml.SyntaxError); ok {
+				return fmt.Errorf("config.xml is not well formed: %v (offending element: %s)", err, elementAt(config, e.Line))
+			}
+			return fmt.Errorf("config.xml is not well formed: %v", err)
+		}
+	}
+}
+
+var (
+	// tags matches the HTML tags in error pages.
+	tags = regexp.MustCompile(`<[^>]*>`)
+	// exception matches the first line of a Java exception in error pages.
+	exception = regexp.MustCompile(`(?m)^\s*((?:[\w$]+\.)+[\w$]*(?:Exception|Error)\b.*)$`)
+	// xstreamPath matches the path of the offending element reported by XStream,
+	// e.g. "path : /project/builders/hudson.tasks.Shell/command".
+	xstreamPath = regexp.MustCompile(`(?m)^\s*path\s*:\s*(/\S+)`)
+	// lineNumber matches the line of the offending element reported by XStream or by
+	// the XML parser (e.g. "line number : 12" or "lineNumber: 12;").
+	lineNumber = regexp.MustCompile(`(?i)line\s*number\s*:\s*(\d+)`)
+	// version matches the XML declaration of documents in XML 1.1, which Jenkins
+	// writes but encoding/xml does not support.
+	version = regexp.MustCompile(`^\s*<\?xml\s+version\s*=\s*["']1\.1["']`)
+)
+
+// decoder returns a decoder of the given document, which is read as XML 1.0
+// if it is declared as XML 1.1; since the declaration keeps its length, the
+// offsets and the lines reported by the decoder are those of the document.
+func decoder(document []byte) *xml.Decoder {
+	if location := version.FindIndex(document); location != nil {
+		document = append([]byte{}, document...)
+		copy(document[location[1]-4:], "1.0")
+	}
+	return xml.NewDecoder(bytes.NewReader(document))
+}
+
+// explanation returns the first line of the exception in the given error page,
+// or its first line of text.
+func explanation(page string) string {
+	text := html.UnescapeString(tags.ReplaceAllString(page, ""))
+	if match := exception.FindStringSubmatch(text); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+// offending returns the path of the element that the server complained about
+// in the given error page: the one reported by XStream, if any, or the element
+// at the reported line of the config.xml.
+func offending(page string, config []byte) string {
+	text := html.UnescapeString(tags.ReplaceAllString(page, ""))
+	if match := xstreamPath.FindStringSubmatch(text); match != nil {
+		return match[1]
+	}
+	if match := lineNumber.FindStringSubmatch(text); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		if path := elementAt(config, n); path != "" {
+			return fmt.Sprintf("%s, line %d", path, n)
+		}
+	}
+	return ""
+}
+
+// elementAt returns the path of the first element starting at the given line
+// of the given document or, if none does, of the innermost element open there.
+func elementAt(document []byte, n int) string {
+	d := decoder(document)
+	var path []string
+	for {
+		offset := d.InputOffset()
+		token, err := d.Token()
+		if err != nil || bytes.Count(document[:offset], []byte("\n"))+1 > n {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			if bytes.Count(document[:offset], []byte("\n"))+1 == n {
+				return "/" + strings.Join(path, "/")
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+	if len(path) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(path, "/")
+}
@@ -2,14 +2,16 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/dihedron/jted/generator"
 	"github.com/dihedron/jted/sax"
-	"github.com/dihedron/jted/stack"
 )
 
 /*
@@ -30,7 +32,20 @@ A utility to generate interactively the Golang template of a Jenkins job
 configuration file, for use as input to the Terraform Jenkins provider.
 
 usage:
-  $> jted [-include-empty-values] [-embed-template] <config.xml>
+  $> jted [-include-empty-values] [-embed-template] [-naming <mode>] 
+           [-parameterise-attributes <names>] [-verify] [-output <name>] 
+           [-format <format>] [-variables] [-module <directory>]
+           [-escape-values] [-fidelity] [-rules <rules.json>]
+           [-resource-attributes <mappings>] [-import <mode>]
+           <config.xml> [<config.xml>...]
+  $> jted -recursive [-combined] [-group] [-output <directory>] [<options>...]
+           <jenkins home>
+  $> jted fetch -url <url> [options] [<job>...]
+     (use "jted fetch -h" for details)
+  $> jted render [options] <parameters>
+     (use "jted render -h" for details)
+  $> jted push -url <url> [options] <parameters>
+     (use "jted push -h" for details)
 where:
   -include-empty-values
     specifies whether empty tags in the original config.xml should be used
@@ -39,73 +54,419 @@ where:
   -embed-template
     specifies whether the generated config.xml template should be embedded 
 	in the generated HCL (.tf) file as a template field [default: false]
-  config.xml [in]  is the original, non-generic Jenkins job configuration file
+  -naming <mode>
+    specifies how parameter names are derived from the XML elements: "leaf"
+	uses the tag name only, "path" uses the path of the element in the 
+	document (e.g. TriggersGitLabPushTriggerSpec), "unique" uses the tag 
+	name plus a numeric suffix on collisions (e.g. Spec2) [default: leaf]
+  -parameterise-attributes <names>
+    specifies a comma-separated list of attributes (e.g. "plugin,class") 
+	whose values should be turned into template parameters; use "*" to 
+	parameterise all attributes [default: none]
+  -verify
+    specifies whether the generated template and parameters should be 
+	rendered back and compared with the original config.xml, ignoring 
+	whitespaces and attributes order; any difference is reported along 
+	with the path of the element where it was found [default: false]
+  -escape-values
+    specifies whether all parameter references in the template should be
+	wrapped in the html function, which XML-escapes the values when the
+	template is rendered, so that no value can break the document; if not
+	specified, only the references to values that need escaping in the
+	original config.xml (and to the description and display name) are 
+	wrapped [default: false]
+  -fidelity
+    specifies whether the text of elements should be preserved exactly:
+	parameter values keep their leading and trailing whitespaces, multiline
+	values are written as heredocs in HCL2, and elements with mixed content
+	(text and child elements) are kept as they are instead of being 
+	parameterised [default: false, that is values are trimmed]
+  -rules <rules.json>
+    specifies a JSON file with rules controlling which elements and 
+	attributes are turned into parameters and how, e.g.
+	  {
+	    "include": ["//hudson.plugins.git.UserRemoteConfig/url"],
+	    "exclude": ["/project/keepDependencies", "//*[@plugin]/@plugin"],
+	    "parameters": [
+	      {"select": "//triggers//spec", "name": "Schedule", 
+	       "type": "string", "default": "H 2 * * *"}
+	    ]
+	  }
+	where "include" lists the elements and attributes to parameterise, even
+	if empty or not selected by -parameterise-attributes, "exclude" lists 
+	the ones to keep as they are (along with their contents), unless they
+	are explicitly included, and "parameters" provide the name, the type in
+	the HCL (string, number or bool) and the value to write in the HCL in 
+	place of the original one; selectors are paths of element names, where
+	* matches any characters in a name, // (or **) any number of elements,
+	[@name] and [@name=value] (or !=, ^=, $=, *= for different from, 
+	starting with, ending with and containing the value) are conditions on 
+	attributes, and a final @name step selects an attribute [default: none]
+  -resource-attributes <mappings>
+    specifies a comma-separated list of children of the root element that
+	are mapped to top level attributes of the jenkins_job resource, instead
+	of parameters, as <tag>=<attribute>; the value is moved from the 
+	parameters map to the resource, unless the :keep suffix is added (e.g.
+	disabled=disabled:keep), and "none" disables all mappings; mappings can
+	also be provided in the rules file, as a "resource" list of objects with
+	"tag", "attribute" and "keep" fields; the name and template attributes
+	cannot be mapped [default: displayName=display_name,disabled=disabled,
+	description=description]
+  -import <mode>
+    specifies how the generated resources adopt the existing jobs, so that
+	Terraform does not recreate them: "blocks" writes a Terraform 1.5+
+	import block after each resource (and implies -format hcl2), "script"
+	writes an imports.sh script of terraform import commands next to the
+	HCL, and "none" writes nothing; the id of each job is its name, derived
+	from the path of its config.xml [default: none]
+  -format <format>
+    specifies the syntax of the generated HCL: "hcl1" for the legacy 
+	Terraform 0.11 syntax, "hcl2" for the Terraform 0.12+ syntax, where
+	the template is referenced through the file() function and template
+	sequences in values are escaped [default: hcl1]
+  -variables
+    specifies whether each parameter should be exposed as a Terraform
+	variable, declared in a variables.tf file (with type, default value and
+	the path of the originating element) and valued in a terraform.tfvars
+	file, both next to the generated HCL; implies -format hcl2 and can only
+	be used with a single config.xml [default: false]
+  -module <directory>
+    specifies that a reusable Terraform module should be generated in the
+	given directory instead of the .hcl and .tpl files: main.tf, with the
+	jenkins_job resource, variables.tf, where the job name, display name,
+	description and the parameters are declared as inputs, outputs.tf,
+	templates/config.xml.tpl and README.md, documenting the inputs; 
+	implies -format hcl2 and can only be used with a single config.xml 
+	[default: none]
+  -output <name>
+    specifies the path and base name of the generated files, which will
+	have the .tpl and .hcl extensions [default: the first config.xml]; in
+	recursive mode, the output directory [default: current directory]
+  -recursive
+    specifies that the argument is a JENKINS_HOME (or jobs) directory, whose 
+	jobs, i.e. all the jobs/<name>/config.xml files including those in 
+	folders, are processed one by one: the generated files are written into
+	the output directory, in the same layout as the tree, and the jobs that
+	could not be processed are listed at the end of the run, instead of
+	aborting it; not compatible with -module [default: false]
+  -combined
+    specifies that, in recursive mode, a single main.tf file is written into 
+	the output directory, with one jenkins_job resource per job; the 
+	templates go next to it, named after the resources, and the variables
+	are prefixed with the resource names [default: false]
+  -group
+    specifies that, in recursive mode, the jobs with the same structure (the
+	same elements and attributes, and the same plugins, regardless of their
+	values) share a template, with one jenkins_job resource per job, as if 
+	they were provided together as a family; the files of each group go into
+	a group_<n> directory (or are named group_<n> when combined); not 
+	compatible with -variables [default: false]
+  config.xml [in]  is the original, non-generic Jenkins job configuration file;
+	if several files of the same job family (i.e. with the same structure) 
+	are provided, only the values that differ across them are turned into
+	parameters, and one jenkins_job resource is generated for each file
+
+The jenkins_job resources are named after the jobs, whose names are derived 
+from the paths of the files, as Jenkins stores them in jobs/<name>/config.xml 
+(and jobs/<folder>/jobs/<name>/config.xml in folders); the display name, the
+description and the disabled flag are taken from the file.
+
+The Pipeline script of jobs defined by an inline Jenkinsfile (CpsFlowDefinition)
+is written as is to a .groovy file next to the template, and loaded into the
+corresponding parameter with the file() function, unless the template is 
+embedded.
+
+Secrets, i.e. values encrypted by Jenkins (e.g. {AQAAABAAAAAQ...}) and values 
+of tags and attributes such as <password> or <apiToken>, are never written to
+the HCL: they are replaced by references to sensitive variables, declared in 
+a variables.tf file and valued with placeholders in a terraform.tfvars file, 
+and listed in a summary at the end of the run.
 `
 )
 
 // jted <config.xml> <config.tpl> <params.tf>
 func main() {
 
-	includeEmptyValues := flag.Bool("include-empty-values", false, "write all potential values, even empty ones [default: false]")
-	embedTemplate := flag.Bool("embed-template", false, "produce an HCL file with inlined template [default: false]")
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := render(os.Args[2:]); err != nil {
+			log.Fatalf("Error rendering template: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "fetch" {
+		if err := fetch(os.Args[2:]); err != nil {
+			log.Fatalf("Error fetching jobs: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "push" {
+		if err := push(os.Args[2:]); err != nil {
+			log.Fatalf("Error pushing job: %v", err)
+		}
+		return
+	}
+
+	options := newOptions(flag.CommandLine)
+	verify := flag.Bool("verify", false, "check that the template and parameters reproduce the original file [default: false]")
+	output := flag.String("output", "", "the path and base name of the generated files [default: the first config.xml]")
+	recursive := flag.Bool("recursive", false, "process all the jobs in a JENKINS_HOME or jobs directory [default: false]")
+	combined := flag.Bool("combined", false, "write a single main.tf with all the jobs in recursive mode [default: false]")
+	grouped := flag.Bool("group", false, "share templates among the jobs with the same structure in recursive mode [default: false]")
+	module := flag.String("module", "", "the directory where a reusable Terraform module should be generated [default: none]")
 	flag.Parse()
 
-	if len(flag.Args()) != 1 {
-		fmt.Println(usage)
+	if len(flag.Args()) < 1 {
+		fmt.Print(usage)
 		os.Exit(1)
 	}
 
-	handler := &Handler{
-		IncludeEmptyValues: *includeEmptyValues,
-		EmbedConfigXML:     *embedTemplate,
-		stack:              stack.New(),
-		parameters:         map[string]string{},
+	newHandler, err := options.factory(*module)
+	if err != nil {
+		log.Fatalf("Error parsing command line: %v", err)
+	}
+
+	if *recursive {
+		if *module != "" || len(flag.Args()) != 1 {
+			log.Fatalf("Error parsing command line: recursive mode requires a single directory and no module")
+		}
+		if *grouped && *options.variables {
+			log.Fatalf("Error parsing command line: variables cannot be generated for groups of jobs")
+		}
+		if *output == "" {
+			*output = "."
+		}
+		runBatch(&Batch{
+			Root:     flag.Args()[0],
+			Output:   *output,
+			Combined: *combined,
+			Group:    *grouped,
+			Verify:   *verify,
+			Handler:  newHandler,
+		})
+		return
+	}
+
+	var names []string
+	for _, name := range flag.Args() {
+		names = append(names, generator.JobName(name))
+	}
+
+	handler := newHandler()
+	handler.Names = names
+	handler.Deferred = len(flag.Args()) > 1
+	if *output == "" {
+		*output = flag.Args()[0]
+	}
+	handler.TemplateFile = getConfigXMLTemplateFileName(*output)
+	if *module != "" {
+		handler.TemplateFile = filepath.Join(*module, "templates", "config.xml.tpl")
 	}
 
 	parser := &sax.Parser{
-		EventHandler: handler,
-		ErrorHandler: handler,
+		EventHandler:   handler,
+		ErrorHandler:   handler,
+		LexicalHandler: handler,
 	}
 
-	file, err := os.Open(flag.Args()[0])
-	if err != nil {
-		log.Fatalf("Error opening input file: %v", err)
+	var inputs [][]byte
+	for _, name := range flag.Args() {
+		input, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatalf("Error reading input file: %v", err)
+		}
+		if err = parser.Parse(bytes.NewReader(input)); err != nil {
+			log.Fatalf("Error parsing input file %s: %v", name, err)
+		}
+		inputs = append(inputs, input)
 	}
-	defer file.Close()
-	err = parser.Parse(file)
-	if err != nil {
-		log.Fatalf("Error parsing input file: %v", err)
+	if handler.Deferred {
+		// several files of the same family, generate a single template
+		if err := handler.Generate(handler.Documents...); err != nil {
+			log.Fatalf("Error generating template: %v", err)
+		}
+	}
+	for _, warning := range handler.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if len(handler.Withheld) > 0 {
+		fmt.Fprintf(os.Stderr, "%d secret(s) withheld from the HCL, to be provided through sensitive variables:\n", len(handler.Withheld))
+		for _, withheld := range handler.Withheld {
+			fmt.Fprintf(os.Stderr, "  %s\n", withheld)
+		}
 	}
 
-	hcl, err := openFile(getHCLFileName(flag.Args()[0]))
-	if err != nil {
-		log.Fatalf("Error opening HCL for writing: %v", err)
+	var differences []string
+	if *verify {
+		for i, input := range inputs {
+			d, err := handler.Verify(i, input)
+			if err != nil {
+				log.Fatalf("Error verifying template against %s: %v", flag.Args()[i], err)
+			}
+			for _, difference := range d {
+				fmt.Fprintf(os.Stderr, "Difference in %s: %s\n", flag.Args()[i], difference)
+			}
+			differences = append(differences, d...)
+		}
 	}
-	defer hcl.Close()
 
-	hclWriter := bufio.NewWriter(hcl)
-	if handler.EmbedConfigXML {
-		// if the template is embedded, then only the HCL should be written out as is,
-		// it contains everything already
-		hclWriter.Write(handler.HCL.Bytes())
-		hclWriter.Flush()
+	if handler.Module != "" {
+		if err := writeModule(handler); err != nil {
+			log.Fatalf("Error writing module: %v", err)
+		}
 	} else {
-		// if the tempate is not embedded, the HCL must be written out after replacing
-		// the name of the tpl file (a "%s" was left by the handler in the buffer for
-		// this purpose)...
-		hclWriter.WriteString(fmt.Sprintf(handler.HCL.String(), getConfigXMLTemplateFileName(flag.Args()[0])))
+		hcl, err := openFile(getHCLFileName(*output))
+		if err != nil {
+			log.Fatalf("Error opening HCL for writing: %v", err)
+		}
+		defer hcl.Close()
+
+		hclWriter := bufio.NewWriter(hcl)
+		hclWriter.Write(handler.HCL.Bytes())
 		hclWriter.Flush()
+		if !handler.EmbedConfigXML {
+			// if the tempate is not embedded, it must be written out too to its own writer
+			tpl, err := openFile(handler.TemplateFile)
+			if err != nil {
+				log.Fatalf("Error opening config.xml template file for writing: %v", err)
+			}
+			defer tpl.Close()
+			tplWriter := bufio.NewWriter(tpl)
 
-		// ... and then the template must be written out too to its own writer
-		tpl, err := openFile(getConfigXMLTemplateFileName(flag.Args()[0]))
-		if err != nil {
-			log.Fatalf("Error opening config.xml template file for writing: %v", err)
+			tplWriter.Write(handler.ConfigXML.Bytes())
+			tplWriter.Flush()
+		}
+	}
+
+	for name, script := range handler.Scripts {
+		if err := writeFile(name, []byte(script)); err != nil {
+			log.Fatalf("Error writing Pipeline script: %v", err)
 		}
-		defer tpl.Close()
-		tplWriter := bufio.NewWriter(tpl)
+	}
 
-		tplWriter.Write(handler.ConfigXML.Bytes())
-		tplWriter.Flush()
+	if handler.ImportScript.Len() > 0 {
+		// terraform import commands go next to the HCL
+		script := append([]byte(generator.ImportScriptHeader), handler.ImportScript.Bytes()...)
+		if err := writeScript(filepath.Join(filepath.Dir(*output), "imports.sh"), script); err != nil {
+			log.Fatalf("Error writing import script: %v", err)
+		}
+	}
+
+	if handler.VariablesTF.Len() > 0 && handler.Module == "" {
+		// variables declarations and values go next to the HCL
+		if err := writeFile(filepath.Join(filepath.Dir(*output), "variables.tf"), handler.VariablesTF.Bytes()); err != nil {
+			log.Fatalf("Error writing variables file: %v", err)
+		}
+		if err := writeFile(filepath.Join(filepath.Dir(*output), "terraform.tfvars"), handler.TFVars.Bytes()); err != nil {
+			log.Fatalf("Error writing variables values file: %v", err)
+		}
+	}
+
+	if len(differences) > 0 {
+		log.Fatalf("Verification failed: %d difference(s) found", len(differences))
+	}
+}
+
+// options holds the command line options shaping the handlers.
+type options struct {
+	includeEmptyValues *bool
+	embedTemplate      *bool
+	naming             *string
+	attributes         *string
+	format             *string
+	variables          *bool
+	escapeValues       *bool
+	fidelity           *bool
+	rules              *string
+	resourceAttributes *string
+	imports            *string
+}
+
+// newOptions defines the command line options shaping the handlers in the
+// given flag set.
+func newOptions(flags *flag.FlagSet) *options {
+	return &options{
+		includeEmptyValues: flags.Bool("include-empty-values", false, "write all potential values, even empty ones [default: false]"),
+		embedTemplate:      flags.Bool("embed-template", false, "produce an HCL file with inlined template [default: false]"),
+		naming:             flags.String("naming", "leaf", "how parameter names are derived: leaf, path or unique [default: leaf]"),
+		attributes:         flags.String("parameterise-attributes", "", "comma-separated list of attributes to parameterise, or * for all [default: none]"),
+		format:             flags.String("format", "hcl1", "the syntax of the generated HCL: hcl1 or hcl2 [default: hcl1]"),
+		variables:          flags.Bool("variables", false, "expose parameters as Terraform variables in variables.tf and terraform.tfvars [default: false]"),
+		escapeValues:       flags.Bool("escape-values", false, "XML-escape all parameter values when the template is rendered [default: false]"),
+		fidelity:           flags.Bool("fidelity", false, "preserve text and whitespaces exactly [default: false]"),
+		rules:              flags.String("rules", "", "the JSON file with the parameterisation rules [default: none]"),
+		resourceAttributes: flags.String("resource-attributes", "", "the comma-separated <tag>=<attribute>[:keep] mappings of elements to resource attributes [default: from the rules, or the built-in ones]"),
+		imports:            flags.String("import", "none", "how the resources adopt the existing jobs: none, blocks or script [default: none]"),
+	}
+}
+
+// factory returns a function creating handlers configured according to the
+// options, once parsed, and generating a module in the given directory, if
+// any.
+func (o *options) factory(module string) (func() *generator.Handler, error) {
+	mode, err := generator.ParseNamingMode(*o.naming)
+	if err != nil {
+		return nil, err
+	}
+	syntax, err := generator.ParseFormat(*o.format)
+	if err != nil {
+		return nil, err
+	}
+	var rules *generator.Rules
+	if *o.rules != "" {
+		if rules, err = generator.LoadRules(*o.rules); err != nil {
+			return nil, err
+		}
+	}
+	var mappings []*generator.ResourceAttribute
+	if *o.resourceAttributes != "" {
+		if mappings, err = generator.ParseResourceAttributes(*o.resourceAttributes); err != nil {
+			return nil, err
+		}
+	}
+	imports, err := generator.ParseImportMode(*o.imports)
+	if err != nil {
+		return nil, err
+	}
+	if imports != generator.NoImports && module != "" {
+		return nil, fmt.Errorf("imports cannot be generated for modules")
+	}
+	if *o.variables || module != "" || imports == generator.ImportBlocks {
+		// variables can only be referenced, and import blocks only written, in
+		// the HCL2 syntax
+		syntax = generator.HCL2Format
+	}
+	return func() *generator.Handler {
+		return generator.NewHandler(generator.Options{
+			IncludeEmptyValues: *o.includeEmptyValues,
+			EmbedConfigXML:     *o.embedTemplate,
+			Naming:             mode,
+			Attributes:         generator.ParseAttributes(*o.attributes),
+			Format:             syntax,
+			Variables:          *o.variables,
+			EscapeValues:       *o.escapeValues,
+			Fidelity:           *o.fidelity,
+			Rules:              rules,
+			ResourceAttributes: mappings,
+			Module:             module,
+			Imports:            imports,
+		})
+	}, nil
+}
+
+// runBatch runs the given batch and prints the summary of the run; it exits
+// with an error if any job could not be processed.
+func runBatch(batch *Batch) {
+	if err := batch.Run(); err != nil {
+		log.Fatalf("Error processing jobs: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d job(s) processed, %d group(s) sharing a template, %d failure(s)\n", batch.Jobs, batch.Groups, len(batch.Failures))
+	for _, failure := range batch.Failures {
+		fmt.Fprintf(os.Stderr, "  %s\n", failure)
+	}
+	if len(batch.Failures) > 0 {
+		os.Exit(1)
 	}
 }
 
@@ -117,6 +478,47 @@ func getConfigXMLTemplateFileName(configXML string) string {
 	return filepath.Dir(configXML) + "/" + filepath.Base(configXML) + ".tpl"
 }
 
+// writeModule writes the files of the Terraform module generated by the
+// handler into its directory.
+func writeModule(handler *generator.Handler) error {
+	if err := os.MkdirAll(filepath.Join(handler.Module, "templates"), 0755); err != nil {
+		return err
+	}
+	files := map[string]*bytes.Buffer{
+		"main.tf":      &handler.HCL,
+		"variables.tf": &handler.VariablesTF,
+		"outputs.tf":   &handler.OutputsTF,
+		"README.md":    &handler.README,
+	}
+	if !handler.EmbedConfigXML {
+		files[filepath.Join("templates", "config.xml.tpl")] = &handler.ConfigXML
+	}
+	for name, data := range files {
+		if err := writeFile(filepath.Join(handler.Module, name), data.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	file, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+// writeScript writes the given shell script, executable, into a new file.
+func writeScript(path string, data []byte) error {
+	if err := writeFile(path, data); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0755)
+}
+
 func openFile(path string) (file *os.File, err error) {
 	if _, err = os.Stat(path); err == nil {
 		fmt.Fprintf(os.Stderr, "File %s exists already\n", path)
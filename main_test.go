@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dihedron/jted/generator"
+	"github.com/dihedron/jted/hcl"
+	"github.com/dihedron/jted/jenkins"
+	"github.com/dihedron/jted/sax"
+)
+
+func TestBatch(t *testing.T) {
+	root, err := ioutil.TempDir("", "jenkins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for path, document := range map[string]string{
+		"config.xml":                       `<hudson/>`,
+		"jobs/a/config.xml":                `<project><description>A</description></project>`,
+		"jobs/a/builds/1/config.xml":       `<project/>`,
+		"jobs/team/config.xml":             `<com.cloudbees.hudson.plugins.folder.Folder/>`,
+		"jobs/team/jobs/b/config.xml":      `<project><description>B</description></project>`,
+		"jobs/team/jobs/broken/config.xml": `<project>`,
+	} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755)
+		ioutil.WriteFile(filepath.Join(root, path), []byte(document), 0644)
+	}
+	output := filepath.Join(root, "output")
+	batch := &Batch{
+		Root:     root,
+		Output:   output,
+		Combined: true,
+		Handler: func() *generator.Handler {
+			return generator.NewHandler(generator.Options{Format: generator.HCL2Format})
+		},
+	}
+	if err := batch.Run(); err != nil {
+		t.Fatalf("error processing jobs: %v", err)
+	}
+	if batch.Jobs != 4 || len(batch.Failures) != 1 || !strings.Contains(batch.Failures[0], "broken") {
+		t.Errorf("invalid outcome: %d job(s), failures %v", batch.Jobs, batch.Failures)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(output, "main.tf"))
+	if err != nil {
+		t.Fatalf("error reading combined file: %v", err)
+	}
+	for _, label := range []string{"a", "team", "team_b"} {
+		if !strings.Contains(string(data), fmt.Sprintf("resource \"jenkins_job\" %q", label)) {
+			t.Errorf("invalid combined file: resource %s not found in\n%s", label, data)
+		}
+		if _, err := os.Stat(filepath.Join(output, label+".xml.tpl")); err != nil {
+			t.Errorf("template not found: %v", err)
+		}
+	}
+
+	// jobs a and team/b have the same structure
+	batch.Output, batch.Group = filepath.Join(root, "grouped"), true
+	batch.Failures = nil
+	if err := batch.Run(); err != nil {
+		t.Fatalf("error processing jobs: %v", err)
+	}
+	if batch.Groups != 1 || len(batch.Failures) != 1 {
+		t.Errorf("invalid outcome: %d group(s), failures %v", batch.Groups, batch.Failures)
+	}
+	data, err = ioutil.ReadFile(filepath.Join(batch.Output, "main.tf"))
+	if err != nil {
+		t.Fatalf("error reading combined file: %v", err)
+	}
+	if strings.Count(string(data), `file("${path.module}/group_1.xml.tpl")`) != 2 {
+		t.Errorf("invalid combined file: shared template not found in\n%s", data)
+	}
+
+	// legacy resources refer to the templates relative to the combined file
+	batch.Output, batch.Group = filepath.Join(root, "legacy"), false
+	batch.Failures = nil
+	batch.Handler = func() *generator.Handler {
+		return generator.NewHandler(generator.Options{Format: generator.LegacyFormat})
+	}
+	if err := batch.Run(); err != nil {
+		t.Fatalf("error processing jobs: %v", err)
+	}
+	data, err = ioutil.ReadFile(filepath.Join(batch.Output, "main.tf"))
+	if err != nil {
+		t.Fatalf("error reading combined file: %v", err)
+	}
+	if !strings.Contains(string(data), `"file://./team_b.xml.tpl"`) {
+		t.Errorf("invalid combined file: template path not relative to it in\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(batch.Output, "team_b.xml.tpl")); err != nil {
+		t.Errorf("template not found: %v", err)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/json":
+			fmt.Fprint(w, `{"jobs":[{"_class":"com.cloudbees.hudson.plugins.folder.Folder","name":"team","jobs":[]}]}`)
+		case "/job/team/api/json":
+			fmt.Fprint(w, `{"jobs":[{"_class":"hudson.model.FreeStyleProject","name":"my-app"}]}`)
+		case "/job/team/config.xml":
+			fmt.Fprint(w, `<com.cloudbees.hudson.plugins.folder.Folder/>`)
+		case "/job/team/job/my-app/config.xml":
+			fmt.Fprint(w, `<project><description>My app</description></project>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	output, err := ioutil.TempDir("", "fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(output)
+
+	batch := &Batch{
+		Client: jenkins.New(server.URL, "", ""),
+		Output: output,
+		Verify: true,
+		Handler: func() *generator.Handler {
+			return generator.NewHandler(generator.Options{Format: generator.HCL2Format})
+		},
+	}
+	if err := batch.Run(); err != nil {
+		t.Fatalf("error fetching jobs: %v", err)
+	}
+	if batch.Jobs != 2 || len(batch.Failures) != 0 {
+		t.Errorf("invalid outcome: %d job(s), failures %v", batch.Jobs, batch.Failures)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(output, "jobs", "team", "jobs", "my-app", "config.xml.hcl"))
+	if err != nil {
+		t.Fatalf("error reading HCL: %v", err)
+	}
+	if !strings.Contains(string(data), `name         = "team/my-app"`) {
+		t.Errorf("invalid HCL:\n%s", data)
+	}
+}
+
+func TestRender(t *testing.T) {
+	original, err := ioutil.ReadFile("test/config.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// values looking like expressions or template sequences must be rendered
+	// as they are, and the inline Pipeline script goes into a .groovy file
+	document := strings.NewReplacer(
+		"<description></description>", "<description>${X}</description>",
+		"<noteRegex>Jenkins please retry a build</noteRegex>", "<noteRegex>${X} %{Y}</noteRegex>",
+		"CpsScmFlowDefinition", "CpsFlowDefinition",
+		"<scriptPath>Jenkinsfile</scriptPath>", `<script>node { echo "${env.BRANCH_NAME} %{x}" }</script>`,
+	).Replace(string(original))
+
+	// the values parameterised "by hand" are not provided in the HCL
+	expected := strings.NewReplacer(
+		"{{CISkip}}", "NO VALUE PROVIDED",
+		"{{- BranchFilterType -}}", "NO VALUE PROVIDED",
+	).Replace(document)
+
+	for _, options := range []generator.Options{
+		{Format: generator.LegacyFormat},
+		{Format: generator.HCL2Format, Variables: true},
+	} {
+		dir, err := ioutil.TempDir("", "render")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		handler := generator.NewHandler(options)
+		handler.Names = []string{"my-app"}
+		handler.TemplateFile = filepath.Join(dir, "config.xml.tpl")
+		parser := &sax.Parser{EventHandler: handler, ErrorHandler: handler, LexicalHandler: handler}
+		if err := parser.Parse(strings.NewReader(document)); err != nil {
+			t.Fatalf("error parsing document: %v", err)
+		}
+		// the secret is provided through terraform.tfvars
+		tfvars := strings.Replace(handler.TFVars.String(), "SECRET VALUE HERE", "{AQAAABAAAAAQwt1GRY9q3ZVQO3gt3epgTsk5dMX+jSacfO7NOzm5Eyk=}", 1)
+		files := map[string]string{
+			"main.tf":          handler.HCL.String(),
+			"config.xml.tpl":   handler.ConfigXML.String(),
+			"variables.tf":     handler.VariablesTF.String(),
+			"terraform.tfvars": tfvars,
+		}
+		for name, script := range handler.Scripts {
+			files[filepath.Base(name)] = script
+		}
+		for name, data := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		job, err := loadJob(filepath.Join(dir, "main.tf"), "")
+		if err != nil {
+			t.Fatalf("error loading %s job: %v", options.Format, err)
+		}
+		rendered, err := job.Render()
+		if err != nil {
+			t.Fatalf("error rendering %s job: %v", options.Format, err)
+		}
+		if !reflect.DeepEqual(tokens(t, rendered), tokens(t, []byte(expected))) {
+			t.Errorf("invalid %s rendering:\n%s\nexpected:\n%s", options.Format, rendered, expected)
+		}
+	}
+}
+
+// tokens returns the tokens of the given XML document, without the whitespaces
+// between elements.
+func tokens(t *testing.T, document []byte) []xml.Token {
+	var result []xml.Token
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return result
+		} else if err != nil {
+			t.Fatalf("error parsing %s: %v", document, err)
+		}
+		if text, ok := token.(xml.CharData); ok && len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+		result = append(result, xml.CopyToken(token))
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "evaluate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "script.groovy"), []byte(`echo "${X}"`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "variables.tf"), []byte(`
+variable "branch" {
+  default = "main"
+}
+variable "token" {
+  type = "string"
+}
+`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "terraform.tfvars"), []byte(`token = "$${TOKEN}"`+"\n"), 0644)
+	variables, err := loadVariables(dir)
+	if err != nil {
+		t.Fatalf("error loading variables: %v", err)
+	}
+	for value, expected := range map[string]string{
+		`value = "${X}"`:                                           "${X}",
+		`value = "$${X} %%{Y}"`:                                    "${X} %{Y}",
+		`value = "${var.branch}"`:                                  "main",
+		`value = var.token`:                                        "${TOKEN}",
+		`value = "${file("${path.module}/script.groovy")}"`:        `echo "${X}"`,
+		`value = templatefile("${path.module}/script.groovy", {})`: `echo "${X}"`,
+	} {
+		body, err := hcl.Parse(strings.NewReader(value))
+		if err != nil {
+			t.Fatalf("error parsing %s: %v", value, err)
+		}
+		if actual, err := evaluate(body.Attribute("value"), dir, variables); err != nil || actual != expected {
+			t.Errorf("invalid evaluation of %s: expected %q, got %q (%v)", value, expected, actual, err)
+		}
+	}
+	body, _ := hcl.Parse(strings.NewReader(`value = "${var.missing}"`))
+	if _, err := evaluate(body.Attribute("value"), dir, variables); err == nil {
+		t.Errorf("reference to a missing variable evaluated")
+	}
+}
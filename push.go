@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dihedron/jted/jenkins"
+)
+
+const (
+	pushUsage = `
+usage:
+  $> jted push -url <url> [-user <user>] [-token <token>] [-name <job name>]
+               [-resource <name>] [-template <config.xml.tpl>] [-dry-run]
+               <parameters>
+where:
+  -url <url>
+    specifies the base URL of the Jenkins server (or of a stand-in), e.g.
+	https://jenkins.example.com/
+  -user <user>
+    specifies the user to authenticate as [default: $JENKINS_USER]
+  -token <token>
+    specifies the API token (or the password) of the user [default:
+	$JENKINS_TOKEN]
+  -name <job name>
+    specifies the full name of the job (e.g. folder/name) to create or
+	update [default: the name attribute of the resource]
+  -resource <name>, -template <config.xml.tpl>
+    as in the render subcommand
+  -dry-run
+    specifies that the rendered config.xml should only be checked to be well
+	formed, without sending it to the server [default: false]
+  parameters [in]  is the HCL file generated by jted, a .tfvars file or a JSON
+	file providing the jenkins_job attributes, as in the render subcommand
+
+The config.xml is rendered as in the render subcommand and POSTed to the job's
+config.xml if the job exists, or to createItem otherwise; if the server rejects
+it, the offending element is reported, when the server's error tells.
+`
+)
+
+// push implements the "push" subcommand: it renders the config.xml of a
+// jenkins_job resource and sends it to a Jenkins server, so that template
+// authors can check that a template actually works.
+func push(args []string) error {
+	flags := flag.NewFlagSet("push", flag.ExitOnError)
+	base := flags.String("url", "", "the base URL of the Jenkins server")
+	user := flags.String("user", os.Getenv("JENKINS_USER"), "the user to authenticate as [default: $JENKINS_USER]")
+	token := flags.String("token", os.Getenv("JENKINS_TOKEN"), "the API token of the user [default: $JENKINS_TOKEN]")
+	name := flags.String("name", "", "the full name of the job [default: the name attribute of the resource]")
+	resource := flags.String("resource", "", "the name of the jenkins_job resource to render [default: the first one]")
+	tpl := flags.String("template", "", "the config.xml template, overriding the resource's template attribute [default: none]")
+	dryRun := flags.Bool("dry-run", false, "only check that the rendered config.xml is well formed [default: false]")
+	flags.Usage = func() { fmt.Fprint(os.Stderr, pushUsage) }
+	flags.Parse(args)
+
+	if len(flags.Args()) != 1 || (*base == "" && !*dryRun) {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	job, err := loadJob(flags.Args()[0], *resource)
+	if err != nil {
+		return err
+	}
+	if *tpl != "" {
+		data, err := ioutil.ReadFile(*tpl)
+		if err != nil {
+			return fmt.Errorf("error reading template: %v", err)
+		}
+		job.Template = string(data)
+	}
+	if *name == "" {
+		if n, ok := job.Attributes["name"].(string); ok && !strings.HasPrefix(n, "<") {
+			*name = n
+		} else {
+			return fmt.Errorf("no valid job name in the resource, please specify one")
+		}
+	}
+
+	rendered, err := job.Render()
+	if err != nil {
+		return err
+	}
+	if err := jenkins.WellFormed(rendered); err != nil {
+		return fmt.Errorf("rendered %v", err)
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "Job %s rendered to a well-formed config.xml\n", *name)
+		return nil
+	}
+	created, err := jenkins.New(*base, *user, *token).Push(*name, rendered)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Job %s created\n", *name)
+	} else {
+		fmt.Fprintf(os.Stderr, "Job %s updated\n", *name)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dihedron/jted/generator"
+	"github.com/dihedron/jted/hcl"
+)
+
+const (
+	renderUsage = `
+usage:
+  $> jted render [-resource <name>] [-template <config.xml.tpl>]
+                 [-output <config.xml>] <parameters>
+where:
+  -resource <name>
+    specifies the name of the jenkins_job resource to render, when the
+	parameters file contains more than one [default: the first one]
+  -template <config.xml.tpl>
+    specifies the config.xml template to use, overriding the template
+	attribute of the resource [default: none]
+  -output <config.xml>
+    specifies the file where the rendered config.xml should be written
+	[default: standard output]
+  parameters [in]  is the HCL file generated by jted, a .tfvars file or a JSON
+	file providing the jenkins_job attributes (parameters, description...);
+	references to input variables (var.<name>) are resolved using the
+	terraform.tfvars and variables.tf files in the same directory, if any
+`
+)
+
+// render implements the "render" subcommand: it reads the attributes of a
+// jenkins_job resource and applies them to its template, the same way the
+// Terraform Jenkins provider does before POSTing the config.xml to the server.
+func render(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	resource := flags.String("resource", "", "the name of the jenkins_job resource to render [default: the first one]")
+	tpl := flags.String("template", "", "the config.xml template, overriding the resource's template attribute [default: none]")
+	output := flags.String("output", "", "the file where the config.xml should be written [default: standard output]")
+	flags.Usage = func() { fmt.Fprint(os.Stderr, renderUsage) }
+	flags.Parse(args)
+
+	if len(flags.Args()) != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	job, err := loadJob(flags.Args()[0], *resource)
+	if err != nil {
+		return err
+	}
+	if *tpl != "" {
+		data, err := ioutil.ReadFile(*tpl)
+		if err != nil {
+			return fmt.Errorf("error reading template: %v", err)
+		}
+		job.Template = string(data)
+	}
+
+	xml, err := job.Render()
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(xml)
+		return err
+	}
+	file, err := openFile(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(xml)
+	return err
+}
+
+// loadJob reads the jenkins_job attributes from an HCL, .tfvars or JSON file;
+// if the file declares jenkins_job resources, the one with the given name (or
+// the first one in HCL files) is used, otherwise the top level attributes are.
+func loadJob(path string, resource string) (*generator.Job, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening parameters file: %v", err)
+	}
+	defer file.Close()
+
+	var attributes map[string]interface{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		var document map[string]interface{}
+		if err := json.NewDecoder(file).Decode(&document); err != nil {
+			return nil, fmt.Errorf("error parsing parameters file: %v", err)
+		}
+		attributes = document
+		if resources, ok := document["resource"].(map[string]interface{}); ok {
+			jobs, _ := resources["jenkins_job"].(map[string]interface{})
+			attributes, err = selectJob(jobs, resource)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		body, err := hcl.Parse(file)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing parameters file: %v", err)
+		}
+		attributes = body.Attributes
+		jobs := map[string]interface{}{}
+		var first string
+		for _, block := range body.Blocks {
+			if block.Type == "resource" && len(block.Labels) == 2 && block.Labels[0] == "jenkins_job" {
+				if first == "" {
+					first = block.Labels[1]
+				}
+				jobs[block.Labels[1]] = block.Body.Attributes
+			}
+		}
+		if len(jobs) > 0 {
+			if resource == "" {
+				resource = first
+			}
+			if attributes, err = selectJob(jobs, resource); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	variables, err := loadVariables(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range attributes {
+		if attributes[k], err = evaluate(v, filepath.Dir(path), variables); err != nil {
+			return nil, fmt.Errorf("error evaluating attribute %s: %v", k, err)
+		}
+	}
+
+	job := &generator.Job{Attributes: attributes}
+	switch t := attributes["template"].(type) {
+	case nil:
+	case string:
+		if strings.HasPrefix(t, "file://") {
+			name := strings.TrimPrefix(t, "file://")
+			if _, err := os.Stat(name); err != nil && !filepath.IsAbs(name) {
+				// try relative to the directory of the parameters file
+				name = filepath.Join(filepath.Dir(path), name)
+			}
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("error reading template: %v", err)
+			}
+			job.Template = string(data)
+		} else {
+			job.Template = t
+		}
+	default:
+		return nil, fmt.Errorf("unsupported template attribute: %v", t)
+	}
+	return job, nil
+}
+
+var (
+	// sequences matches escaped template sequences and references to the module
+	// path in HCL strings.
+	sequences = regexp.MustCompile(`\$\$\{|%%\{|\$\{path\.(module|root)\}`)
+	// interpolation matches the legacy HCL strings made of a single supported
+	// expression, e.g. "${var.name}" or "${file("config.groovy")}"; any other
+	// string, e.g. "${X}", is literal text.
+	interpolation = regexp.MustCompile(`^\$\{((?:var|path)\.[\w-]+|(?:file|templatefile)\(.*\))\}$`)
+)
+
+// evaluate resolves the expressions that can be found in parameters files:
+// escaped template sequences ($${ and %%{), references to the module path and
+// calls to the file() and templatefile() functions, which return the contents
+// of the given file (templatefile() does not apply Terraform's templating,
+// since the template is a Go template), and references to input variables;
+// legacy strings made of a single supported expression are evaluated as such.
+func evaluate(value interface{}, dir string, variables map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if match := interpolation.FindStringSubmatch(v); match != nil {
+			if body, err := hcl.Parse(strings.NewReader("value = " + match[1])); err == nil {
+				return evaluate(body.Attribute("value"), dir, variables)
+			}
+		}
+		return sequences.ReplaceAllStringFunc(v, func(sequence string) string {
+			switch sequence {
+			case "$${":
+				return "${"
+			case "%%{":
+				return "%{"
+			}
+			return dir
+		}), nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if result[i], err = evaluate(item, dir, variables); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			var err error
+			if result[k], err = evaluate(item, dir, variables); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case *hcl.Call:
+		if (v.Name != "file" && v.Name != "templatefile") || len(v.Arguments) == 0 {
+			return nil, fmt.Errorf("unsupported function %s()", v.Name)
+		}
+		name, err := evaluate(v.Arguments[0], dir, variables)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := name.(string); !ok {
+			return nil, fmt.Errorf("invalid argument to %s()", v.Name)
+		}
+		data, err := ioutil.ReadFile(name.(string))
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case hcl.Reference:
+		if v == "path.module" || v == "path.root" {
+			return dir, nil
+		}
+		if value, ok := variables[strings.TrimPrefix(string(v), "var.")]; ok && strings.HasPrefix(string(v), "var.") {
+			return evaluate(value, dir, variables)
+		}
+		return nil, fmt.Errorf("unsupported reference to %s", v)
+	}
+	return value, nil
+}
+
+// loadVariables returns the values of the input variables declared in the
+// variables.tf file in the given directory, if any: the values in the
+// terraform.tfvars file take precedence over the defaults.
+func loadVariables(dir string) (map[string]interface{}, error) {
+	variables := map[string]interface{}{}
+	for _, name := range []string{"variables.tf", "terraform.tfvars"} {
+		file, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error opening %s: %v", name, err)
+		}
+		body, err := hcl.Parse(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", name, err)
+		}
+		for _, block := range body.Blocks {
+			if block.Type == "variable" && len(block.Labels) == 1 {
+				if value, ok := block.Body.Attributes["default"]; ok {
+					variables[block.Labels[0]] = value
+				}
+			}
+		}
+		if name == "terraform.tfvars" {
+			for k, v := range body.Attributes {
+				variables[k] = v
+			}
+		}
+	}
+	return variables, nil
+}
+
+// selectJob returns the attributes of the jenkins_job with the given name, or
+// of the only one available if no name is provided.
+func selectJob(jobs map[string]interface{}, name string) (map[string]interface{}, error) {
+	if name == "" {
+		if len(jobs) != 1 {
+			return nil, fmt.Errorf("%d jenkins_job resources found, please specify which one to render", len(jobs))
+		}
+		for k := range jobs {
+			name = k
+		}
+	}
+	attributes, ok := jobs[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("jenkins_job resource %q not found", name)
+	}
+	return attributes, nil
+}
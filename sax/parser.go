@@ -1,8 +1,11 @@
 package sax
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/xml"
 	"io"
+	"regexp"
 )
 
 // EventHandler is the interface definig the methods that handle relevant SAX
@@ -44,20 +47,152 @@ type ErrorHandler interface {
 	OnError(err error) error
 }
 
+// LexicalHandler is the optional interface defining the methods that handle
+// the lexical details of an XML document, which do not affect its contents but
+// should be preserved when the document is reproduced.
+type LexicalHandler interface {
+	// OnDirective is invoked whenever there is a directive (e.g. <!DOCTYPE ...>);
+	// the delimiters <! and > are omitted.
+	OnDirective(element xml.Directive) error
+
+	// OnStartCDATA is called at the beginning of a CDATA section (<![CDATA[);
+	// the contents of the section are reported through OnCharacterData.
+	OnStartCDATA() error
+
+	// OnEndCDATA is called at the end of a CDATA section (]]>).
+	OnEndCDATA() error
+}
+
+// Locator reports where, in the document being parsed, the token that raised
+// the event being handled begins; lines and columns start at 1, and columns
+// count characters, not bytes.
+type Locator struct {
+	Line   int // the line of the token
+	Column int // the column of the token
+	offset int // the offset of the token in the document
+}
+
+// LocatorHandler is the optional interface of the event handlers that need to
+// know where the events come from in the document: the parser provides them
+// with a Locator before the document starts, and keeps it up to date as it
+// reports the events.
+type LocatorHandler interface {
+	// SetDocumentLocator is invoked before OnStartDocument, with the Locator
+	// the parser updates for each event.
+	SetDocumentLocator(locator *Locator)
+}
+
+// advance moves the locator forward past the given bytes of the input.
+func (l *Locator) advance(input []byte) {
+	for _, r := range string(input) {
+		if r == '\n' {
+			l.Line++
+			l.Column = 1
+		} else {
+			l.Column++
+		}
+	}
+	l.offset += len(input)
+}
+
 // Parser is an implementation of a SAX parser.
 type Parser struct {
-	EventHandler EventHandler
-	ErrorHandler ErrorHandler
+	EventHandler   EventHandler
+	ErrorHandler   ErrorHandler
+	LexicalHandler LexicalHandler
+}
+
+// version matches the XML declaration of documents in XML 1.1, which Jenkins
+// writes but encoding/xml does not support; they are parsed as XML 1.0 and
+// reported with their original version.
+var version = regexp.MustCompile(`^\s*<\?xml\s+version\s*=\s*["']1\.1["']`)
+
+// prolog returns a reader of the given input where the version in the XML
+// declaration, if any, is 1.0, and whether it was 1.1; only the beginning of
+// the input is read in advance to patch it.
+func prolog(reader io.Reader) (io.Reader, bool) {
+	head := make([]byte, 128)
+	n, err := io.ReadFull(reader, head)
+	head = head[:n]
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	rest := reader
+	if err != nil {
+		// the error is reported after the beginning of the input
+		rest = &failing{err}
+	}
+	location := version.FindIndex(head)
+	if location != nil {
+		copy(head[location[1]-4:], "1.0")
+	}
+	return io.MultiReader(bytes.NewReader(head), rest), location != nil
+}
+
+// failing is a reader returning the given error.
+type failing struct {
+	err error
+}
+
+// Read returns the error.
+func (f *failing) Read(p []byte) (int, error) {
+	return 0, f.err
+}
+
+// recorder is the io.ByteReader the decoder reads the input from, one byte at
+// a time, so that the bytes of the tokens are known without reading the whole
+// document: it records the bytes read and not yet flushed.
+type recorder struct {
+	reader *bufio.Reader
+	bytes  []byte // the bytes recorded
+	offset int64  // the offset of the first recorded byte in the input
+}
+
+// ReadByte reads and records the next byte of the input.
+func (r *recorder) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.bytes = append(r.bytes, b)
+	}
+	return b, err
+}
+
+// Read is only there to satisfy io.Reader, the decoder reads through ReadByte.
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.bytes = append(r.bytes, p[:n]...)
+	return n, err
 }
 
-// Parse parses an XML document and invokes the SAX handlers' methods.
+// flush returns the bytes recorded up to the given offset of the input, and
+// stops recording them; the decoder may have read a byte past its offset.
+func (r *recorder) flush(offset int64) []byte {
+	n := int(offset - r.offset)
+	flushed := r.bytes[:n:n]
+	r.bytes, r.offset = r.bytes[n:], offset
+	return flushed
+}
+
+// Parse parses an XML document and invokes the SAX handlers' methods; if any
+// of them returns an error, the processing is aborted.
 func (p *Parser) Parse(reader io.Reader) error {
-	var err error
-	d := xml.NewDecoder(reader)
-	p.EventHandler.OnStartDocument()
+	reader, patched := prolog(reader)
+	input := &recorder{reader: bufio.NewReader(reader)}
+	d := xml.NewDecoder(input)
+	locator := &Locator{Line: 1, Column: 1}
+	if handler, ok := p.EventHandler.(LocatorHandler); ok {
+		handler.SetDocumentLocator(locator)
+	}
+	err := p.EventHandler.OnStartDocument()
+	if err != nil {
+		return err
+	}
 loop:
 	for {
-		token, err := d.Token()
+		var token xml.Token
+		// the bytes recorded up to here precede the token
+		locator.advance(input.flush(d.InputOffset()))
+		token, err = d.Token()
 		switch {
 		case err == io.EOF && token == nil:
 			// done reading the document
@@ -80,25 +215,46 @@ loop:
 		default:
 			switch token := token.(type) {
 			case xml.StartElement:
-				p.EventHandler.OnStartElement(token.Copy())
+				err = p.EventHandler.OnStartElement(token.Copy())
 			case xml.CharData:
-				p.EventHandler.OnCharacterData(token.Copy())
+				// CDATA sections are reported as separate character data tokens
+				cdata := bytes.HasPrefix(input.bytes, []byte("<![CDATA["))
+				if cdata && p.LexicalHandler != nil {
+					if err = p.LexicalHandler.OnStartCDATA(); err != nil {
+						break loop
+					}
+				}
+				if err = p.EventHandler.OnCharacterData(token.Copy()); err != nil {
+					break loop
+				}
+				if cdata && p.LexicalHandler != nil {
+					err = p.LexicalHandler.OnEndCDATA()
+				}
 			case xml.EndElement:
-				p.EventHandler.OnEndElement(token)
+				err = p.EventHandler.OnEndElement(token)
 			case xml.Comment:
-				p.EventHandler.OnComment(token.Copy())
+				err = p.EventHandler.OnComment(token.Copy())
 			case xml.ProcInst:
-				p.EventHandler.OnProcessingInstruction(token.Copy())
-			default:
-				// unsupported token type???
+				token = token.Copy()
+				if patched && token.Target == "xml" {
+					token.Inst = bytes.Replace(token.Inst, []byte("1.0"), []byte("1.1"), 1)
+				}
+				err = p.EventHandler.OnProcessingInstruction(token)
+			case xml.Directive:
+				if p.LexicalHandler != nil {
+					err = p.LexicalHandler.OnDirective(token.Copy())
+				}
+			}
+			if err != nil {
+				break loop
 			}
 		}
 	}
 	return err
 }
 
-// DefaultHandler is the default, do-nothing implementation of the EventHandler
-// and ErrorHandler interfaces.
+// DefaultHandler is the default, do-nothing implementation of the EventHandler,
+// ErrorHandler and LexicalHandler interfaces.
 type DefaultHandler struct{}
 
 // OnStartDocument is the default, do-nothing implementation of the corresponding
@@ -143,6 +299,24 @@ func (h *DefaultHandler) OnEndDocument() error {
 	return nil
 }
 
+// OnDirective is the default, do-nothing implementation of the corresponding
+// LexicalHandler interface.
+func (h *DefaultHandler) OnDirective(element xml.Directive) error {
+	return nil
+}
+
+// OnStartCDATA is the default, do-nothing implementation of the corresponding
+// LexicalHandler interface.
+func (h *DefaultHandler) OnStartCDATA() error {
+	return nil
+}
+
+// OnEndCDATA is the default, do-nothing implementation of the corresponding
+// LexicalHandler interface.
+func (h *DefaultHandler) OnEndCDATA() error {
+	return nil
+}
+
 // OnError is the default implementation of the corresponding ErrorHandler
 // interface; it simply forwards any error to the Parser.
 func (h *DefaultHandler) OnError(err error) error {
@@ -0,0 +1,200 @@
+package sax
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Path is a compiled XPath-like expression selecting elements, or attributes
+// of elements, in an XML document, e.g.
+//
+//	/flow-definition/properties/*/triggers//spec
+//	//scm[@class='hudson.plugins.git.GitSCM']//url
+//	//*[@plugin^='gitlab-plugin']/@plugin
+//
+// Paths are sequences of steps separated by /: each step matches an element
+// by its local name, where * matches any sequence of characters; an empty step
+// (as in //) or ** matches any number of elements, and a final @name step (or
+// @*) selects an attribute. Paths not starting with / match anywhere in the
+// document. Steps can have conditions on the element's attributes: [@name]
+// requires the attribute, [@name=value] (or !=, ^=, $=, *=) also requires
+// its value to be equal to (different from, start with, end with, contain) the
+// given one, which can be quoted.
+type Path struct {
+	expression string
+	steps      []step
+	attribute  string
+}
+
+// step matches one element, or any number of elements if any is set.
+type step struct {
+	name       string
+	any        bool
+	conditions []condition
+}
+
+// condition is a condition on the attributes of an element.
+type condition struct {
+	attribute string
+	operator  string // "" if the attribute only needs to be present
+	value     string
+}
+
+// predicate matches the conditions on attributes in the steps of a Path.
+var predicate = regexp.MustCompile(`\[@([^\]=!^$*]+)(?:(=|!=|\^=|\$=|\*=)(?:'([^']*)'|"([^"]*)"|([^\]'"]*)))?\]`)
+
+// Compile parses an XPath-like expression into a Path.
+func Compile(expression string) (*Path, error) {
+	if expression == "" {
+		return nil, fmt.Errorf("invalid path: empty expression")
+	}
+	s := expression
+	if !strings.HasPrefix(s, "/") {
+		s = "//" + s
+	}
+	p := &Path{expression: expression}
+	parts := split(s[1:])
+	for i, part := range parts {
+		if strings.HasPrefix(part, "@") {
+			if i != len(parts)-1 || len(part) == 1 {
+				return nil, fmt.Errorf("invalid path %q: attributes can only be selected at the end", expression)
+			}
+			p.attribute = part[1:]
+			break
+		}
+		if part == "" || part == "**" {
+			if i == len(parts)-1 {
+				return nil, fmt.Errorf("invalid path %q: trailing /", expression)
+			}
+			p.steps = append(p.steps, step{any: true})
+			continue
+		}
+		st := step{name: part}
+		if index := strings.Index(part, "["); index >= 0 {
+			st.name = part[:index]
+			predicates := part[index:]
+			if predicate.ReplaceAllString(predicates, "") != "" {
+				return nil, fmt.Errorf("invalid path %q: bad condition %s", expression, predicates)
+			}
+			for _, match := range predicate.FindAllStringSubmatch(predicates, -1) {
+				st.conditions = append(st.conditions, condition{
+					attribute: match[1],
+					operator:  match[2],
+					value:     match[3] + match[4] + match[5],
+				})
+			}
+		}
+		if _, err := path.Match(st.name, ""); err != nil || st.name == "" {
+			return nil, fmt.Errorf("invalid path %q: bad element name %q", expression, st.name)
+		}
+		p.steps = append(p.steps, st)
+	}
+	return p, nil
+}
+
+// MustCompile is like Compile, but panics if the expression is invalid.
+func MustCompile(expression string) *Path {
+	p, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the original expression.
+func (p *Path) String() string {
+	return p.expression
+}
+
+// Attribute returns the name of the attribute selected by the Path (* for any
+// attribute), or an empty string if the Path selects elements.
+func (p *Path) Attribute() string {
+	return p.attribute
+}
+
+// Match returns whether the Path selects the element at the end of the given
+// elements, from the root of the document, or its attribute with the given
+// name, if not empty.
+func (p *Path) Match(elements []xml.StartElement, attribute string) bool {
+	if p.attribute != attribute && !(p.attribute == "*" && attribute != "") {
+		return false
+	}
+	return match(p.steps, elements)
+}
+
+// split splits an expression into its steps, ignoring the slashes in conditions.
+func split(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case r == '/' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// match returns whether the steps match the given elements.
+func match(steps []step, elements []xml.StartElement) bool {
+	if len(steps) == 0 {
+		return len(elements) == 0
+	}
+	if steps[0].any {
+		for i := 0; i <= len(elements); i++ {
+			if match(steps[1:], elements[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(elements) > 0 && steps[0].matches(elements[0]) && match(steps[1:], elements[1:])
+}
+
+// matches returns whether the step matches the given element.
+func (st step) matches(element xml.StartElement) bool {
+	if ok, _ := path.Match(st.name, element.Name.Local); !ok {
+		return false
+	}
+	for _, c := range st.conditions {
+		value, ok := attribute(element, c.attribute)
+		if !ok {
+			return false
+		}
+		switch c.operator {
+		case "=":
+			ok = value == c.value
+		case "!=":
+			ok = value != c.value
+		case "^=":
+			ok = strings.HasPrefix(value, c.value)
+		case "$=":
+			ok = strings.HasSuffix(value, c.value)
+		case "*=":
+			ok = strings.Contains(value, c.value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// attribute returns the value of the attribute of the element with the given
+// local name, if any.
+func attribute(element xml.StartElement, name string) (string, bool) {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,190 @@
+package sax
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func elements(names ...string) []xml.StartElement {
+	result := make([]xml.StartElement, len(names))
+	for i, name := range names {
+		attributes := strings.Split(name, " ")
+		result[i].Name.Local = attributes[0]
+		for _, attribute := range attributes[1:] {
+			pair := strings.SplitN(attribute, "=", 2)
+			result[i].Attr = append(result[i].Attr, xml.Attr{Name: xml.Name{Local: pair[0]}, Value: pair[1]})
+		}
+	}
+	return result
+}
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		elements   []xml.StartElement
+		attribute  string
+		expected   bool
+	}{
+		{"/project/description", elements("project", "description"), "", true},
+		{"/project/description", elements("project", "builders", "description"), "", false},
+		{"description", elements("project", "builders", "description"), "", true},
+		{"/flow-definition/properties/*/triggers//spec", elements("flow-definition", "properties", "org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty", "triggers", "hudson.triggers.TimerTrigger", "spec"), "", true},
+		{"/flow-definition/properties/*/triggers//spec", elements("flow-definition", "properties", "triggers", "spec"), "", false},
+		{"/project/**/url", elements("project", "scm", "url"), "", true},
+		{"//hudson.*Trigger/spec", elements("project", "triggers", "hudson.triggers.SCMTrigger", "spec"), "", true},
+		{"//*[@plugin^='gitlab-plugin']", elements("project", "trigger plugin=gitlab-plugin@1.5.13"), "", true},
+		{"//*[@plugin^='gitlab-plugin']", elements("project", "trigger plugin=git@4.0.0"), "", false},
+		{"//*[@plugin$=\"@4.0.0\"]", elements("project", "scm plugin=git@4.0.0"), "", true},
+		{"//*[@plugin*=git]", elements("project", "scm plugin=gitlab-plugin@1.5.13"), "", true},
+		{"//scm[@class!=hudson.scm.NullSCM]", elements("project", "scm class=hudson.scm.NullSCM"), "", false},
+		{"//scm[@class][@plugin]", elements("project", "scm class=hudson.plugins.git.GitSCM"), "", false},
+		{"//scm[@class='a/b']/url", elements("project", "scm class=a/b", "url"), "", true},
+		{"//scm/@plugin", elements("project", "scm plugin=git@4.0.0"), "plugin", true},
+		{"//scm/@plugin", elements("project", "scm plugin=git@4.0.0"), "", false},
+		{"//scm", elements("project", "scm plugin=git@4.0.0"), "plugin", false},
+		{"//@*", elements("project", "scm plugin=git@4.0.0"), "plugin", true},
+	}
+	for _, test := range tests {
+		path, err := Compile(test.expression)
+		if err != nil {
+			t.Errorf("error compiling %q: %v", test.expression, err)
+			continue
+		}
+		if path.Match(test.elements, test.attribute) != test.expected {
+			t.Errorf("invalid match of %q on %v (attribute %q): expected %t", test.expression, test.elements, test.attribute, test.expected)
+		}
+	}
+
+	for _, expression := range []string{"", "/project/", "/project/@plugin/url", "//scm[class]", "//scm[@class=a", "/project/[@a]"} {
+		if _, err := Compile(expression); err == nil {
+			t.Errorf("invalid expression %q compiled", expression)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	document := `<?xml version='1.1' encoding='UTF-8'?>
+<flow-definition plugin="workflow-job@2.40">
+  <properties>
+    <org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty>
+      <triggers>
+        <hudson.triggers.TimerTrigger>
+          <spec>H 2 * * *</spec>
+        </hudson.triggers.TimerTrigger>
+        <hudson.triggers.SCMTrigger>
+          <spec>H/5 * * * *</spec>
+        </hudson.triggers.SCMTrigger>
+      </triggers>
+    </org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty>
+  </properties>
+</flow-definition>`
+	router := NewRouter(&DefaultHandler{})
+	var specs, plugins []string
+	router.Handle("/flow-definition/properties/*/triggers//spec", func(match *Match) error {
+		specs = append(specs, match.Text)
+		if len(match.Elements) != 6 {
+			t.Errorf("invalid number of elements: expected 6, got %d", len(match.Elements))
+		}
+		return nil
+	})
+	router.Handle("//@plugin", func(match *Match) error {
+		plugins = append(plugins, match.Attribute.Value)
+		return nil
+	})
+	parser := &Parser{EventHandler: router}
+	if err := parser.Parse(strings.NewReader(document)); err != nil {
+		t.Fatalf("error parsing document: %v", err)
+	}
+	if strings.Join(specs, ",") != "H 2 * * *,H/5 * * * *" {
+		t.Errorf("invalid matches: %q", specs)
+	}
+	if strings.Join(plugins, ",") != "workflow-job@2.40" {
+		t.Errorf("invalid matches: %q", plugins)
+	}
+
+	// lexical events reach the wrapped handler through the router
+	handler := &lexical{}
+	router = NewRouter(handler)
+	parser = &Parser{EventHandler: router, LexicalHandler: router}
+	if err := parser.Parse(strings.NewReader(`<!DOCTYPE project><project><script><![CDATA[a < b]]></script></project>`)); err != nil {
+		t.Fatalf("error parsing document: %v", err)
+	}
+	if strings.Join(handler.events, ",") != "DOCTYPE project,<![CDATA[,]]>" {
+		t.Errorf("invalid lexical events: %q", handler.events)
+	}
+}
+
+// lexical records the lexical events.
+type lexical struct {
+	DefaultHandler
+	events []string
+}
+
+func (l *lexical) OnDirective(element xml.Directive) error {
+	l.events = append(l.events, string(element))
+	return nil
+}
+
+func (l *lexical) OnStartCDATA() error {
+	l.events = append(l.events, "<![CDATA[")
+	return nil
+}
+
+func (l *lexical) OnEndCDATA() error {
+	l.events = append(l.events, "]]>")
+	return nil
+}
+
+// locations records where the elements start.
+type locations struct {
+	DefaultHandler
+	locator *Locator
+	starts  []string
+}
+
+func (l *locations) SetDocumentLocator(locator *Locator) {
+	l.locator = locator
+}
+
+func (l *locations) OnStartElement(element xml.StartElement) error {
+	l.starts = append(l.starts, fmt.Sprintf("%s:%d:%d", element.Name.Local, l.locator.Line, l.locator.Column))
+	return nil
+}
+
+func TestLocator(t *testing.T) {
+	handler := &locations{}
+	parser := &Parser{EventHandler: NewRouter(handler)}
+	err := parser.Parse(strings.NewReader("<?xml version='1.1'?>\n<project>\n  <description>é</description><disabled/>\n  <!-- x\n  -->\n\t<builders/>\n</project>"))
+	if err != nil {
+		t.Fatalf("error parsing document: %v", err)
+	}
+	if strings.Join(handler.starts, ",") != "project:2:1,description:3:3,disabled:3:31,builders:6:2" {
+		t.Errorf("invalid locations: %v", handler.starts)
+	}
+}
+
+// broken is a reader failing after the given input.
+type broken struct {
+	input *strings.Reader
+}
+
+func (b *broken) Read(p []byte) (int, error) {
+	if b.input.Len() == 0 {
+		return 0, fmt.Errorf("connection reset")
+	}
+	return b.input.Read(p)
+}
+
+func TestStreaming(t *testing.T) {
+	handler := &locations{}
+	parser := &Parser{EventHandler: handler}
+	err := parser.Parse(&broken{strings.NewReader("<?xml version='1.1' encoding='UTF-8'?>\n<project>\n  <description>")})
+	if err == nil || err.Error() != "connection reset" {
+		t.Errorf("invalid error: %v", err)
+	}
+	if strings.Join(handler.starts, ",") != "project:2:1,description:3:3" {
+		t.Errorf("elements not reported before the end of the input: %v", handler.starts)
+	}
+}
@@ -0,0 +1,206 @@
+package sax
+
+import (
+	"bytes"
+	"encoding/xml"
+
+	"github.com/dihedron/jted/stack"
+)
+
+// Match describes the element or attribute selected by a Path while the
+// document is being parsed.
+type Match struct {
+	Elements  []xml.StartElement // the elements from the root to the matched one
+	Attribute *xml.Attr          // the matched attribute, nil if an element matched
+	Text      string             // the character data directly inside the matched element
+}
+
+// Callback is a function invoked by a Router on each match of a Path; if it
+// returns an error, the parsing is aborted.
+type Callback func(match *Match) error
+
+// Router is an EventHandler that keeps track of the path of the current
+// element and invokes the callbacks registered against the matching Paths:
+// elements are reported when they end, so that their text is available, and
+// attributes when their element starts. All events are forwarded to the
+// wrapped EventHandler, if any, before the callbacks are invoked; lexical
+// events are forwarded too, if it is also a LexicalHandler.
+type Router struct {
+	EventHandler EventHandler
+	stack        *stack.Stack
+	routes       []route
+}
+
+// route is a Path along with its callback.
+type route struct {
+	path     *Path
+	callback Callback
+}
+
+// frame is an open element along with its character data.
+type frame struct {
+	element xml.StartElement
+	text    bytes.Buffer
+}
+
+// NewRouter creates a new Router, forwarding all events to the given
+// EventHandler, which can be nil.
+func NewRouter(handler EventHandler) *Router {
+	return &Router{
+		EventHandler: handler,
+		stack:        stack.New(),
+	}
+}
+
+// Handle registers a callback against the given XPath-like expression (see
+// Path); callbacks are invoked in order of registration.
+func (r *Router) Handle(expression string, callback Callback) error {
+	path, err := Compile(expression)
+	if err != nil {
+		return err
+	}
+	r.Route(path, callback)
+	return nil
+}
+
+// Route registers a callback against the given compiled Path; callbacks are
+// invoked in order of registration.
+func (r *Router) Route(path *Path, callback Callback) {
+	r.routes = append(r.routes, route{path: path, callback: callback})
+}
+
+// Elements returns the elements from the root of the document to the current
+// one.
+func (r *Router) Elements() []xml.StartElement {
+	frames := r.stack.Elements()
+	elements := make([]xml.StartElement, len(frames))
+	for i, f := range frames {
+		elements[i] = f.(*frame).element
+	}
+	return elements
+}
+
+// SetDocumentLocator forwards the locator, if the wrapped EventHandler needs
+// it.
+func (r *Router) SetDocumentLocator(locator *Locator) {
+	if handler, ok := r.EventHandler.(LocatorHandler); ok {
+		handler.SetDocumentLocator(locator)
+	}
+}
+
+// OnStartDocument resets the Router and forwards the event.
+func (r *Router) OnStartDocument() error {
+	r.stack.Clear()
+	if r.EventHandler != nil {
+		return r.EventHandler.OnStartDocument()
+	}
+	return nil
+}
+
+// OnProcessingInstruction forwards the event.
+func (r *Router) OnProcessingInstruction(element xml.ProcInst) error {
+	if r.EventHandler != nil {
+		return r.EventHandler.OnProcessingInstruction(element)
+	}
+	return nil
+}
+
+// OnStartElement pushes the element, forwards the event and invokes the
+// callbacks of the Paths matching the element's attributes.
+func (r *Router) OnStartElement(element xml.StartElement) error {
+	r.stack.Push(&frame{element: element})
+	if r.EventHandler != nil {
+		if err := r.EventHandler.OnStartElement(element); err != nil {
+			return err
+		}
+	}
+	var elements []xml.StartElement
+	for _, route := range r.routes {
+		if route.path.Attribute() == "" {
+			continue
+		}
+		if elements == nil {
+			elements = r.Elements()
+		}
+		for i := range element.Attr {
+			if route.path.Match(elements, element.Attr[i].Name.Local) {
+				if err := route.callback(&Match{Elements: elements, Attribute: &element.Attr[i]}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// OnEndElement forwards the event, invokes the callbacks of the Paths matching
+// the element and pops it.
+func (r *Router) OnEndElement(element xml.EndElement) error {
+	if r.EventHandler != nil {
+		if err := r.EventHandler.OnEndElement(element); err != nil {
+			return err
+		}
+	}
+	elements := r.Elements()
+	top, _ := r.stack.Pop().(*frame)
+	for _, route := range r.routes {
+		if top != nil && route.path.Match(elements, "") {
+			if err := route.callback(&Match{Elements: elements, Text: top.text.String()}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// OnCharacterData records the text of the current element and forwards the
+// event.
+func (r *Router) OnCharacterData(element xml.CharData) error {
+	if top, ok := r.stack.Top().(*frame); ok {
+		top.text.Write(element)
+	}
+	if r.EventHandler != nil {
+		return r.EventHandler.OnCharacterData(element)
+	}
+	return nil
+}
+
+// OnComment forwards the event.
+func (r *Router) OnComment(element xml.Comment) error {
+	if r.EventHandler != nil {
+		return r.EventHandler.OnComment(element)
+	}
+	return nil
+}
+
+// OnEndDocument forwards the event.
+func (r *Router) OnEndDocument() error {
+	if r.EventHandler != nil {
+		return r.EventHandler.OnEndDocument()
+	}
+	return nil
+}
+
+// OnDirective forwards the event, if the wrapped EventHandler handles it.
+func (r *Router) OnDirective(element xml.Directive) error {
+	if handler, ok := r.EventHandler.(LexicalHandler); ok {
+		return handler.OnDirective(element)
+	}
+	return nil
+}
+
+// OnStartCDATA forwards the event, if the wrapped EventHandler handles it.
+func (r *Router) OnStartCDATA() error {
+	if handler, ok := r.EventHandler.(LexicalHandler); ok {
+		return handler.OnStartCDATA()
+	}
+	return nil
+}
+
+// OnEndCDATA forwards the event, if the wrapped EventHandler handles it.
+func (r *Router) OnEndCDATA() error {
+	if handler, ok := r.EventHandler.(LexicalHandler); ok {
+		return handler.OnEndCDATA()
+	}
+	return nil
+}
@@ -61,6 +61,20 @@ func (s *Stack) Top() interface{} {
 	return nil
 }
 
+// Elements returns a snapshot of the elements in the Stack, ordered from the
+// bottom to the top.
+func (s *Stack) Elements() []interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	elements := make([]interface{}, s.size)
+	i := s.size - 1
+	for e := s.head; e != nil; e = e.next {
+		elements[i] = e.data
+		i--
+	}
+	return elements
+}
+
 // Len returns the size of the Stack.
 func (s *Stack) Len() int {
 	return s.size
@@ -28,3 +28,25 @@ func TestPushLenPop(t *testing.T) {
 		t.Errorf("invalid stack length: expected 0, got %d", stack.Len())
 	}
 }
+
+func TestElements(t *testing.T) {
+	stack := New()
+
+	if len(stack.Elements()) != 0 {
+		t.Errorf("invalid number of elements: expected 0, got %d", len(stack.Elements()))
+	}
+
+	for i := 0; i < 10; i++ {
+		stack.Push(i)
+	}
+
+	elements := stack.Elements()
+	if len(elements) != 10 {
+		t.Errorf("invalid number of elements: expected 10, got %d", len(elements))
+	}
+	for i, element := range elements {
+		if element.(int) != i {
+			t.Errorf("invalid element at %d: expected %d, got %d", i, i, element.(int))
+		}
+	}
+}